@@ -4,16 +4,155 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 // Config holds the application configuration
 type Config struct {
-	ClientID     string `yaml:"client_id"`
-	ClientSecret string `yaml:"client_secret"`
-	BearerToken  string `yaml:"bearer_token"`
-	RedirectURL  string `yaml:"redirect_url"`
+	ClientID     string     `yaml:"client_id"`
+	ClientSecret string     `yaml:"client_secret"`
+	BearerToken  string     `yaml:"bearer_token"`
+	APIKey       string     `yaml:"api_key,omitempty"`
+	APISecret    string     `yaml:"api_secret,omitempty"`
+	RedirectURL  string     `yaml:"redirect_url"`
+	APIBaseURL   string     `yaml:"api_base_url,omitempty"`
+	Retry        Retry      `yaml:"retry,omitempty"`
+	TokenStore   TokenStore `yaml:"token_store,omitempty"`
+	AppOnly      AppOnly    `yaml:"app_only,omitempty"`
+	Disguise     string     `yaml:"disguise,omitempty"`
+
+	// Accounts are additional named accounts; the top-level credentials
+	// are the "default" account
+	Accounts map[string]Account `yaml:"accounts,omitempty"`
+}
+
+// TokenStore selects where OAuth tokens are kept
+type TokenStore struct {
+	// Backend is "file" (default), "encrypted" or "keyring"
+	Backend string `yaml:"backend,omitempty"`
+	// KeyFile keys the encrypted backend; without it the passphrase is
+	// read from XJSON_TOKEN_PASSPHRASE
+	KeyFile string `yaml:"key_file,omitempty"`
+}
+
+// AppOnly sets the view the app opens in with app-only auth, which has no
+// home timeline
+type AppOnly struct {
+	// Search is a recent search query
+	Search string `yaml:"search,omitempty"`
+	// Handles are accounts whose recent tweets are shown
+	Handles []string `yaml:"handles,omitempty"`
+}
+
+// Query returns the search query for the app-only start view, combining
+// the handles and the search, or "" if neither is set
+func (a AppOnly) Query() string {
+	var from []string
+	for _, h := range a.Handles {
+		if h = strings.TrimPrefix(strings.TrimSpace(h), "@"); h != "" {
+			from = append(from, "from:"+h)
+		}
+	}
+
+	var parts []string
+	switch len(from) {
+	case 0:
+	case 1:
+		parts = append(parts, from[0])
+	default:
+		parts = append(parts, "("+strings.Join(from, " OR ")+")")
+	}
+	if s := strings.TrimSpace(a.Search); s != "" {
+		parts = append(parts, s)
+	}
+	return strings.Join(parts, " ")
+}
+
+// DefaultAccount is the name of the account configured at the top level
+const DefaultAccount = "default"
+
+// accountNamePattern is what account names may contain. Names end up in
+// token file names and keyring entries, so path separators are not allowed.
+var accountNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// ValidateAccountName checks that an account name is usable
+func ValidateAccountName(name string) error {
+	if !accountNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid account name %q: use only letters, digits, '-' and '_'", name)
+	}
+	return nil
+}
+
+// Account holds the credentials of one named account. The app settings
+// (client_id, client_secret, redirect_url) are inherited from the top level
+// when empty, so accounts signing in through the same app only need an
+// entry. App-only credentials (bearer_token, api_key, api_secret) are never
+// inherited.
+type Account struct {
+	ClientID     string `yaml:"client_id,omitempty"`
+	ClientSecret string `yaml:"client_secret,omitempty"`
+	BearerToken  string `yaml:"bearer_token,omitempty"`
+	APIKey       string `yaml:"api_key,omitempty"`
+	APISecret    string `yaml:"api_secret,omitempty"`
+	RedirectURL  string `yaml:"redirect_url,omitempty"`
+}
+
+// Account returns the resolved credentials of a named account; an empty
+// name selects the default account
+func (c *Config) Account(name string) (Account, error) {
+	base := Account{
+		ClientID:     c.ClientID,
+		ClientSecret: c.ClientSecret,
+		BearerToken:  c.BearerToken,
+		APIKey:       c.APIKey,
+		APISecret:    c.APISecret,
+		RedirectURL:  c.RedirectURL,
+	}
+	if name == "" || name == DefaultAccount {
+		return base, nil
+	}
+	if err := ValidateAccountName(name); err != nil {
+		return Account{}, err
+	}
+
+	acct, ok := c.Accounts[name]
+	if !ok {
+		return Account{}, fmt.Errorf("unknown account %q (configured: %v)", name, c.AccountNames())
+	}
+	if acct.ClientID == "" {
+		acct.ClientID = base.ClientID
+		if acct.ClientSecret == "" {
+			acct.ClientSecret = base.ClientSecret
+		}
+	}
+	if acct.RedirectURL == "" {
+		acct.RedirectURL = base.RedirectURL
+	}
+	return acct, nil
+}
+
+// AccountNames returns the default account followed by the named accounts
+func (c *Config) AccountNames() []string {
+	names := make([]string, 0, len(c.Accounts)+1)
+	for name := range c.Accounts {
+		if name != DefaultAccount {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultAccount}, names...)
+}
+
+// Retry configures retries of failed requests; zero values keep the defaults
+type Retry struct {
+	MaxAttempts int           `yaml:"max_attempts,omitempty"`
+	BaseDelay   time.Duration `yaml:"base_delay,omitempty"`
+	MaxDelay    time.Duration `yaml:"max_delay,omitempty"`
 }
 
 // DefaultConfigPath returns the default config file path
@@ -50,6 +189,11 @@ func LoadFromPath(path string) (*Config, error) {
 	if err := yaml.Unmarshal(data, &cfg); err != nil {
 		return nil, fmt.Errorf("failed to parse config: %w", err)
 	}
+	for name := range cfg.Accounts {
+		if err := ValidateAccountName(name); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+	}
 
 	// Set defaults
 	if cfg.RedirectURL == "" {
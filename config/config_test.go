@@ -0,0 +1,41 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateAccountName(t *testing.T) {
+	for _, name := range []string{"default", "work", "alt_2", "Side-Project"} {
+		if err := ValidateAccountName(name); err != nil {
+			t.Errorf("ValidateAccountName(%q) = %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "../evil", "a/b", `a\b`, "..", "work.old", "has space"} {
+		if err := ValidateAccountName(name); err == nil {
+			t.Errorf("ValidateAccountName(%q) accepted", name)
+		}
+	}
+}
+
+func TestLoadRejectsBadAccountName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "xjson.yaml")
+	data := "client_id: id\naccounts:\n  work:\n    bearer_token: a\n  ../../tmp/x:\n    bearer_token: b\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadFromPath(path)
+	if err == nil || !strings.Contains(err.Error(), `invalid account name "../../tmp/x"`) {
+		t.Fatalf("LoadFromPath error = %v, want an invalid account name", err)
+	}
+}
+
+func TestAccountRejectsBadName(t *testing.T) {
+	cfg := &Config{ClientID: "id"}
+	if _, err := cfg.Account("../work"); err == nil || !strings.Contains(err.Error(), "invalid account name") {
+		t.Fatalf("Account error = %v, want an invalid account name", err)
+	}
+}
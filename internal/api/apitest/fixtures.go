@@ -0,0 +1,308 @@
+package apitest
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/kenan/xjson/internal/api"
+)
+
+// fixtureUsers are the accounts served by the fake API. The first one is
+// the authenticated user.
+var fixtureUsers = []api.User{
+	{ID: "1500000000000000001", Name: "Dev Tester", Username: "devtester", Description: "Testing things in prod so you don't have to.", ProfileImageURL: "https://pbs.twimg.com/profile_images/1/devtester_normal.jpg", FollowersCount: 312, FollowingCount: 198, TweetCount: 4821},
+	{ID: "1500000000000000002", Name: "Gopher News", Username: "gophernews", Description: "Release notes, proposals and the occasional meme.", ProfileImageURL: "https://pbs.twimg.com/profile_images/2/gophernews_normal.jpg", Verified: true, FollowersCount: 128400, FollowingCount: 41, TweetCount: 9320},
+	{ID: "1500000000000000003", Name: "Ada Byte", Username: "adabyte", Description: "Compilers, coffee, cats.", ProfileImageURL: "https://pbs.twimg.com/profile_images/3/adabyte_normal.jpg", FollowersCount: 5021, FollowingCount: 610, TweetCount: 15022},
+	{ID: "1500000000000000004", Name: "Terminal Tips", Username: "termtips", Description: "One shell trick a day.", ProfileImageURL: "https://pbs.twimg.com/profile_images/4/termtips_normal.jpg", FollowersCount: 22870, FollowingCount: 3, TweetCount: 1207},
+	{ID: "1500000000000000005", Name: "Null Pointer", Username: "nullptr", Description: "Segfaults are a lifestyle.", ProfileImageURL: "https://pbs.twimg.com/profile_images/5/nullptr_normal.jpg", FollowersCount: 940, FollowingCount: 1022, TweetCount: 30411},
+}
+
+// fixtureMedia are attached to some of the generated tweets
+var fixtureMedia = []api.Media{
+	{MediaKey: "3_1800000000000000101", Type: "photo", URL: "https://pbs.twimg.com/media/FxDesk01.jpg", Width: 1600, Height: 900, AltText: "A standing desk with three monitors full of logs"},
+	{MediaKey: "3_1800000000000000102", Type: "photo", URL: "https://pbs.twimg.com/media/FxFlame02.png", Width: 1280, Height: 720, AltText: "CPU flame graph"},
+	{MediaKey: "3_1800000000000000103", Type: "photo", URL: "https://pbs.twimg.com/media/FxBench03.png", Width: 1080, Height: 1080},
+	{MediaKey: "7_1800000000000000104", Type: "video", PreviewImageURL: "https://pbs.twimg.com/ext_tw_video_thumb/104/pu/img/demo.jpg", Width: 1920, Height: 1080, DurationMs: 42500, Variants: []api.MediaVariant{
+		{ContentType: "application/x-mpegURL", URL: "https://video.twimg.com/ext_tw_video/104/pu/pl/demo.m3u8"},
+		{BitRate: 832000, ContentType: "video/mp4", URL: "https://video.twimg.com/ext_tw_video/104/pu/vid/640x360/demo.mp4"},
+		{BitRate: 2176000, ContentType: "video/mp4", URL: "https://video.twimg.com/ext_tw_video/104/pu/vid/1280x720/demo.mp4"},
+	}},
+	{MediaKey: "16_1800000000000000105", Type: "animated_gif", PreviewImageURL: "https://pbs.twimg.com/tweet_video_thumb/FxShip05.jpg", Width: 498, Height: 280, Variants: []api.MediaVariant{
+		{ContentType: "video/mp4", URL: "https://video.twimg.com/tweet_video/FxShip05.mp4"},
+	}},
+}
+
+// fixturePollOptions are the choices of the generated polls
+var fixturePollOptions = [][]api.PollOption{
+	{{Position: 1, Label: "Tabs", Votes: 412}, {Position: 2, Label: "Spaces", Votes: 377}, {Position: 3, Label: "gofmt decides", Votes: 1290}},
+	{{Position: 1, Label: "print", Votes: 233}, {Position: 2, Label: "Debugger", Votes: 96}},
+}
+
+// fixtureTexts are cycled through to build the fake timeline
+var fixtureTexts = []string{
+	"Just shipped a new release. Changelog is longer than the diff, as it should be.",
+	"Reminder: context.Context goes first, and it never goes in a struct.",
+	"Hot take: the best debugger is a well-placed print statement.",
+	"TIL `git worktree` exists and I've been stashing like a caveman for years.",
+	"If your tests pass on the first try, check that they actually ran.",
+	"Spent the morning renaming a variable. Worth it.",
+	"Tabs vs spaces is settled: gofmt decides and we move on.",
+	"Profiling before optimizing saved me a week today. Again.",
+	"ctrl+r in your shell is the most underrated keybinding. Fight me.",
+	"The fix was one character. Finding it took four hours.",
+	"Writing docs is just debugging for future you.",
+	"Benchmarks don't lie, but they do exaggerate.",
+	"Pairing with @adabyte on the new parser today. #golang #compilers",
+	"Wrote up how escape analysis decides what goes on the heap: https://t.co/gH7eScAp1 #golang",
+	"My dotfiles are finally public, aliases and all: https://t.co/tT9dOtF1s cc @termtips",
+	"$GOOG mentioned Go twice on the earnings call. We're basically a finance account now.",
+}
+
+// fixtureLinks are where the t.co links in fixtureTexts lead
+var fixtureLinks = map[string]string{
+	"https://t.co/gH7eScAp1": "https://go.dev/doc/gc-guide#Escape_analysis",
+	"https://t.co/tT9dOtF1s": "https://github.com/termtips/dotfiles",
+	// The root of the fixture thread, which fixtureQuote quotes
+	"https://t.co/qU0tEr00t": "https://x.com/gophernews/status/1800000000000000001",
+}
+
+// fixtureQuote is the text of a tweet quoting the root of the thread
+const fixtureQuote = "Every changelog should read like this one https://t.co/qU0tEr00t"
+
+// fixtureReplies answer the first tweet, forming a small conversation
+var fixtureReplies = []string{
+	"Congrats! Upgrading right now.",
+	"Does this include the fix for the flaky test on Windows?",
+	"Yes, that landed last week.",
+	"Longest changelog I've read all year, loved it.",
+}
+
+// fixtureLongPost is the full text of a post over 280 characters
+const fixtureLongPost = "Things I wish I'd known before writing my first parser: " +
+	"1) write the tests first, the grammar will change under you anyway. " +
+	"2) keep positions on every token from day one, retrofitting them is misery. " +
+	"3) error recovery matters more than speed; nobody cares how fast you reject their code. " +
+	"4) a pretty-printer is the best test oracle you will ever have. " +
+	"5) read the Go parser, it's shorter than you'd think. #compilers"
+
+// fixtureEdits are the earlier versions of an edited post, oldest first.
+// The final version is fixtureTexts[2].
+var fixtureEdits = []string{
+	"Hot take: the best debuger is a print statement.",
+	"Hot take: the best debugger is a print statement.",
+}
+
+// fixtures is the generated data served by the fake API
+type fixtures struct {
+	tweets   []api.Tweet // newest first
+	versions []api.Tweet // earlier versions of edited tweets, only found by ID
+	polls    []api.Poll
+}
+
+// buildFixtures generates a deterministic set of tweets, newest first,
+// relative to now so that recent search still finds them
+func buildFixtures(now time.Time) fixtures {
+	var tweets []api.Tweet
+	var versions []api.Tweet
+	var polls []api.Poll
+	nextID := 1800000000000000000
+
+	newTweet := func(author api.User, text string, createdAt time.Time) api.Tweet {
+		nextID++
+		id := fmt.Sprintf("%d", nextID)
+		seed := nextID % 997
+		return api.Tweet{
+			ID:             id,
+			Text:           text,
+			AuthorID:       author.ID,
+			CreatedAt:      createdAt.UTC().Truncate(time.Second),
+			ConversationID: id,
+			Entities:       fixtureEntities(text),
+			EditHistory:    []string{id},
+			EditControls: &api.EditControls{
+				EditsRemaining: 5,
+				IsEditEligible: true,
+				EditableUntil:  createdAt.UTC().Truncate(time.Second).Add(time.Hour),
+			},
+			Metrics: &api.Metrics{
+				RetweetCount: (seed * 7) % 40,
+				ReplyCount:   (seed * 3) % 15,
+				LikeCount:    (seed * 13) % 400,
+				QuoteCount:   seed % 5,
+				Impressions:  (seed * 131) % 20000,
+			},
+		}
+	}
+
+	// newEdited creates a tweet with earlier versions. Every version lists
+	// the whole history and shares the edit window of the first one.
+	newEdited := func(author api.User, texts []string, createdAt time.Time) api.Tweet {
+		var history []api.Tweet
+		for i, text := range texts {
+			age := time.Duration(len(texts)-1-i) * 8 * time.Minute
+			history = append(history, newTweet(author, text, createdAt.Add(-age)))
+		}
+		ids := make([]string, len(history))
+		for i := range history {
+			ids[i] = history[i].ID
+		}
+		for i := range history {
+			history[i].EditHistory = ids
+			history[i].EditControls = &api.EditControls{
+				EditsRemaining: 5 - (len(history) - 1),
+				IsEditEligible: true,
+				EditableUntil:  history[0].CreatedAt.Add(time.Hour),
+			}
+		}
+		versions = append(versions, history[:len(history)-1]...)
+		return history[len(history)-1]
+	}
+
+	// A thread on the oldest tweet, so conversation lookups have something to find
+	root := newTweet(fixtureUsers[1], fixtureTexts[0], now.Add(-48*time.Hour))
+	tweets = append(tweets, root)
+
+	parent := root
+	for i, text := range fixtureReplies {
+		author := fixtureUsers[(i+2)%len(fixtureUsers)]
+		if i == 2 {
+			author = fixtureUsers[1]
+		}
+		reply := newTweet(author, text, root.CreatedAt.Add(time.Duration(i+1)*7*time.Minute))
+		reply.ConversationID = root.ID
+		reply.InReplyToUserID = parent.AuthorID
+		reply.ReferencedTweets = []api.ReferencedTweet{{Type: "replied_to", ID: parent.ID}}
+		tweets = append(tweets, reply)
+		// The answer replies to the question; the others reply to the root
+		if i == 1 {
+			parent = reply
+		} else {
+			parent = root
+		}
+	}
+
+	// One open and one closed poll, each running for a day
+	newPoll := func(options []api.PollOption, closes time.Time) *api.Attachments {
+		poll := api.Poll{
+			ID:              fmt.Sprintf("%d", 1800000000000000200+len(polls)),
+			Options:         options,
+			DurationMinutes: 1440,
+			EndDatetime:     closes.UTC().Truncate(time.Second),
+			VotingStatus:    "open",
+		}
+		if !closes.After(now) {
+			poll.VotingStatus = "closed"
+		}
+		polls = append(polls, poll)
+		return &api.Attachments{PollIDs: []string{poll.ID}}
+	}
+
+	for i := 0; i < 45; i++ {
+		author := fixtureUsers[i%len(fixtureUsers)]
+		text := fixtureTexts[(i+1)%len(fixtureTexts)]
+		switch i {
+		case 5:
+			text = "RT @" + fixtureUsers[1].Username + ": " + root.Text
+		case 9:
+			text = fixtureQuote
+		}
+		createdAt := now.Add(-time.Duration(i*47+5) * time.Minute)
+
+		var tweet api.Tweet
+		switch i {
+		case 1:
+			tweet = newEdited(author, append(slices.Clone(fixtureEdits), text), createdAt)
+		case 2:
+			// X cuts the text of long posts off, keeping the rest in note_tweet
+			tweet = newTweet(author, string([]rune(fixtureLongPost)[:279])+"…", createdAt)
+			tweet.NoteTweet = &api.NoteTweet{Text: fixtureLongPost, Entities: fixtureEntities(fixtureLongPost)}
+		default:
+			tweet = newTweet(author, text, createdAt)
+		}
+
+		switch i {
+		case 5:
+			tweet.ReferencedTweets = []api.ReferencedTweet{{Type: "retweeted", ID: root.ID}}
+		case 9:
+			tweet.ReferencedTweets = []api.ReferencedTweet{{Type: "quoted", ID: root.ID}}
+		case 3:
+			tweet.Attachments = &api.Attachments{MediaKeys: []string{fixtureMedia[0].MediaKey}}
+		case 7:
+			tweet.Attachments = &api.Attachments{MediaKeys: []string{fixtureMedia[3].MediaKey}}
+		case 11:
+			tweet.Attachments = newPoll(fixturePollOptions[0], tweet.CreatedAt.Add(24*time.Hour))
+		case 16:
+			tweet.Attachments = &api.Attachments{MediaKeys: []string{fixtureMedia[1].MediaKey, fixtureMedia[2].MediaKey}}
+		case 24:
+			tweet.Attachments = &api.Attachments{MediaKeys: []string{fixtureMedia[4].MediaKey}}
+		case 33:
+			tweet.Attachments = newPoll(fixturePollOptions[1], tweet.CreatedAt.Add(24*time.Hour))
+		}
+		tweets = append(tweets, tweet)
+	}
+
+	sortNewestFirst(tweets)
+	return fixtures{tweets: tweets, versions: versions, polls: polls}
+}
+
+// fixtureEntities parses the links, mentions, hashtags and cashtags out of
+// a fixture text the way X does, with offsets in code points
+func fixtureEntities(text string) *api.Entities {
+	var entities api.Entities
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		if runes[start] == ' ' {
+			start++
+			continue
+		}
+		end := start
+		for end < len(runes) && runes[end] != ' ' {
+			end++
+		}
+		word := strings.TrimRight(string(runes[start:end]), ".,!?:;")
+		wordStart, wordEnd := start, start+utf8.RuneCountInString(word)
+		start = end
+
+		switch {
+		case strings.HasPrefix(word, "https://t.co/"):
+			expanded := fixtureLinks[word]
+			entities.URLs = append(entities.URLs, api.URLEntity{
+				Start:       wordStart,
+				End:         wordEnd,
+				URL:         word,
+				ExpandedURL: expanded,
+				DisplayURL:  displayURL(expanded),
+			})
+		case len(word) > 1 && word[0] == '@':
+			mention := api.MentionEntity{Start: wordStart, End: wordEnd, Username: word[1:]}
+			for _, u := range fixtureUsers {
+				if strings.EqualFold(u.Username, mention.Username) {
+					mention.ID = u.ID
+				}
+			}
+			entities.Mentions = append(entities.Mentions, mention)
+		case len(word) > 1 && word[0] == '#':
+			entities.Hashtags = append(entities.Hashtags, api.TagEntity{Start: wordStart, End: wordEnd, Tag: word[1:]})
+		case len(word) > 1 && word[0] == '$' && unicode.IsLetter(rune(word[1])):
+			entities.Cashtags = append(entities.Cashtags, api.TagEntity{Start: wordStart, End: wordEnd, Tag: word[1:]})
+		}
+	}
+
+	if entities.URLs == nil && entities.Mentions == nil && entities.Hashtags == nil && entities.Cashtags == nil {
+		return nil
+	}
+	return &entities
+}
+
+// displayURL shortens a URL the way X displays it
+func displayURL(u string) string {
+	u = strings.TrimPrefix(strings.TrimPrefix(u, "https://"), "http://")
+	if runes := []rune(u); len(runes) > 26 {
+		return string(runes[:25]) + "…"
+	}
+	return u
+}
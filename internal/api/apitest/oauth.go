@@ -0,0 +1,117 @@
+package apitest
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// grantedScope is the scope reported for every token the fake issues
+const grantedScope = "tweet.read users.read offline.access"
+
+// tokenLifetime is the expires_in of issued access tokens, in seconds
+const tokenLifetime = 7200
+
+// handleToken is a minimal OAuth 2.0 token endpoint. Any authorization
+// code is accepted; refresh tokens are one-time, as on X.
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, "invalid_request", err.Error())
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		if r.PostForm.Get("code") == "" || r.PostForm.Get("code_verifier") == "" {
+			writeOAuthError(w, "invalid_request", "code and code_verifier are required")
+			return
+		}
+	case "refresh_token":
+		refresh := r.PostForm.Get("refresh_token")
+		if !s.refreshTokens[refresh] {
+			writeOAuthError(w, "invalid_grant", "Value passed for the token was invalid.")
+			return
+		}
+		delete(s.refreshTokens, refresh)
+	default:
+		writeOAuthError(w, "unsupported_grant_type", "grant_type is not supported")
+		return
+	}
+
+	s.issued++
+	access := fmt.Sprintf("fake-access-%d", s.issued)
+	refresh := fmt.Sprintf("fake-refresh-%d", s.issued)
+	s.refreshTokens[refresh] = true
+
+	writeJSON(w, map[string]interface{}{
+		"token_type":    "bearer",
+		"access_token":  access,
+		"refresh_token": refresh,
+		"expires_in":    tokenLifetime,
+		"scope":         grantedScope,
+	})
+}
+
+// handleAppToken issues app-only tokens for the client credentials grant.
+// Any API key and secret are accepted.
+func (s *Server) handleAppToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, "invalid_request", err.Error())
+		return
+	}
+	if r.PostForm.Get("grant_type") != "client_credentials" {
+		writeOAuthError(w, "unsupported_grant_type", "grant_type must be client_credentials")
+		return
+	}
+	if _, _, ok := r.BasicAuth(); !ok {
+		writeProblem(w, http.StatusForbidden, "Forbidden", "Unable to verify your credentials", "about:blank")
+		return
+	}
+
+	s.mu.Lock()
+	s.issued++
+	token := fmt.Sprintf("fake-app-%d", s.issued)
+	s.mu.Unlock()
+
+	writeJSON(w, map[string]string{"token_type": "bearer", "access_token": token})
+}
+
+// handleRevoke invalidates an access or refresh token
+func (s *Server) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, "invalid_request", err.Error())
+		return
+	}
+	token := r.PostForm.Get("token")
+	if token == "" {
+		writeOAuthError(w, "invalid_request", "token is required")
+		return
+	}
+
+	s.Revoke(token)
+	writeJSON(w, map[string]bool{"revoked": true})
+}
+
+// Revoke invalidates a token as if it had been revoked or rotated, so
+// requests using it get a 401
+func (s *Server) Revoke(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[token] = true
+	delete(s.refreshTokens, token)
+}
+
+// Revoked reports whether a token was sent to the revocation endpoint
+func (s *Server) Revoked(token string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revoked[token]
+}
+
+func writeOAuthError(w http.ResponseWriter, code, description string) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusBadRequest)
+	writeJSON(w, map[string]string{"error": code, "error_description": description})
+}
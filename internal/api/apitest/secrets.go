@@ -0,0 +1,52 @@
+package apitest
+
+import (
+	"sync"
+
+	"github.com/kenan/xjson/internal/api"
+)
+
+// SecretService is an in-memory api.SecretService that stands in for the
+// OS keyring
+type SecretService struct {
+	mu      sync.Mutex
+	secrets map[string]string
+}
+
+// NewSecretService creates an empty secret service
+func NewSecretService() *SecretService {
+	return &SecretService{secrets: make(map[string]string)}
+}
+
+// Get implements api.SecretService
+func (s *SecretService) Get(service, user string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	secret, ok := s.secrets[service+"/"+user]
+	if !ok {
+		return "", api.ErrSecretNotFound
+	}
+	return secret, nil
+}
+
+// Set implements api.SecretService
+func (s *SecretService) Set(service, user, secret string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.secrets[service+"/"+user] = secret
+	return nil
+}
+
+// Delete implements api.SecretService
+func (s *SecretService) Delete(service, user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.secrets[service+"/"+user]; !ok {
+		return api.ErrSecretNotFound
+	}
+	delete(s.secrets, service+"/"+user)
+	return nil
+}
@@ -0,0 +1,437 @@
+// Package apitest provides a fake X API v2 server that serves fixture data,
+// for working on the client and UI without live credentials or quota.
+package apitest
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kenan/xjson/internal/api"
+)
+
+// Options configures a fake server
+type Options struct {
+	// PageSize is used when a request has no max_results
+	PageSize int
+	// RateLimit is the number of requests allowed per endpoint and window
+	RateLimit int
+	// RateWindow is the length of a rate limit window
+	RateWindow time.Duration
+}
+
+// bucket is the rate limit state of one endpoint
+type bucket struct {
+	remaining int
+	reset     time.Time
+}
+
+// Server is a fake X API v2. Mount it under any prefix ending in /2, e.g.
+// httptest.NewServer(apitest.NewServer(apitest.Options{})) and point the
+// client at server.URL + "/2". The OAuth token and revocation endpoints are
+// at /2/oauth2/token and /2/oauth2/revoke, and app-only tokens are issued
+// at /oauth2/token; any bearer token that has not been revoked is accepted.
+type Server struct {
+	opts     Options
+	mux      *http.ServeMux
+	users    []api.User
+	tweets   []api.Tweet
+	versions []api.Tweet // earlier versions of edited tweets, only found by ID
+	media    []api.Media
+	polls    []api.Poll
+
+	mu            sync.Mutex
+	buckets       map[string]*bucket
+	issued        int
+	refreshTokens map[string]bool
+	revoked       map[string]bool
+}
+
+// NewServer creates a fake server with the built-in fixtures
+func NewServer(opts Options) *Server {
+	if opts.PageSize <= 0 {
+		opts.PageSize = 10
+	}
+	if opts.RateLimit <= 0 {
+		opts.RateLimit = 180
+	}
+	if opts.RateWindow <= 0 {
+		opts.RateWindow = 15 * time.Minute
+	}
+
+	data := buildFixtures(time.Now())
+	s := &Server{
+		opts:     opts,
+		mux:      http.NewServeMux(),
+		users:    fixtureUsers,
+		tweets:   data.tweets,
+		versions: data.versions,
+		media:    fixtureMedia,
+		polls:    data.polls,
+		buckets:  make(map[string]*bucket),
+
+		refreshTokens: make(map[string]bool),
+		revoked:       make(map[string]bool),
+	}
+
+	s.mux.HandleFunc("POST /2/oauth2/token", s.handleToken)
+	s.mux.HandleFunc("POST /2/oauth2/revoke", s.handleRevoke)
+	s.mux.HandleFunc("POST /oauth2/token", s.handleAppToken)
+
+	s.handle("GET /2/users/me", api.EndpointMe, s.handleMe)
+	s.handle("GET /2/users/{id}/timelines/reverse_chronological", api.EndpointHomeTimeline, s.handleHomeTimeline)
+	s.handle("GET /2/users/{id}/tweets", api.EndpointUserTweets, s.handleUserTweets)
+	s.handle("GET /2/users/by/username/{username}", api.EndpointUserByName, s.handleUserByName)
+	s.handle("GET /2/tweets/search/recent", api.EndpointSearch, s.handleSearch)
+	s.handle("GET /2/tweets/{id}", api.EndpointTweet, s.handleTweet)
+
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Exhaust uses up the remaining budget of an endpoint (an api.Endpoint*
+// template), so its next request gets a 429
+func (s *Server) Exhaust(endpoint string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b := s.bucketLocked(endpoint)
+	b.remaining = 0
+}
+
+// bucketLocked returns the current window's bucket for an endpoint
+func (s *Server) bucketLocked(endpoint string) *bucket {
+	b, ok := s.buckets[endpoint]
+	if !ok || time.Now().After(b.reset) {
+		b = &bucket{remaining: s.opts.RateLimit, reset: time.Now().Add(s.opts.RateWindow)}
+		s.buckets[endpoint] = b
+	}
+	return b
+}
+
+// handle registers a handler behind auth and rate limit checks
+func (s *Server) handle(pattern, endpoint string, h http.HandlerFunc) {
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			writeProblem(w, http.StatusUnauthorized, "Unauthorized", "Unauthorized", "about:blank")
+			return
+		}
+
+		s.mu.Lock()
+		if s.revoked[token] {
+			s.mu.Unlock()
+			writeProblem(w, http.StatusUnauthorized, "Unauthorized", "Unauthorized", "about:blank")
+			return
+		}
+		b := s.bucketLocked(endpoint)
+		limited := b.remaining <= 0
+		if !limited {
+			b.remaining--
+		}
+		w.Header().Set("x-rate-limit-limit", strconv.Itoa(s.opts.RateLimit))
+		w.Header().Set("x-rate-limit-remaining", strconv.Itoa(b.remaining))
+		w.Header().Set("x-rate-limit-reset", strconv.FormatInt(b.reset.Unix(), 10))
+		s.mu.Unlock()
+
+		if limited {
+			writeProblem(w, http.StatusTooManyRequests, "Too Many Requests", "Too Many Requests", "about:blank")
+			return
+		}
+
+		h(w, r)
+	})
+}
+
+func (s *Server) handleMe(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]interface{}{"data": s.users[0]})
+}
+
+func (s *Server) handleUserByName(w http.ResponseWriter, r *http.Request) {
+	username := r.PathValue("username")
+	for _, u := range s.users {
+		if strings.EqualFold(u.Username, username) {
+			writeJSON(w, map[string]interface{}{"data": u})
+			return
+		}
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"errors": []api.ResourceError{notFound("user", "username", username,
+			fmt.Sprintf("Could not find user with username: [%s].", username))},
+	})
+}
+
+func (s *Server) handleTweet(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if t := s.tweetByID(id); t != nil {
+		writeJSON(w, map[string]interface{}{
+			"data":     t,
+			"includes": s.includesFor([]api.Tweet{*t}),
+		})
+		return
+	}
+
+	writeJSON(w, map[string]interface{}{
+		"errors": []api.ResourceError{notFound("tweet", "id", id,
+			fmt.Sprintf("Could not find tweet with id: [%s].", id))},
+	})
+}
+
+func (s *Server) handleHomeTimeline(w http.ResponseWriter, r *http.Request) {
+	if r.PathValue("id") != s.users[0].ID {
+		writeProblem(w, http.StatusForbidden, "Forbidden", "You can only view your own home timeline.", "about:blank")
+		return
+	}
+	s.writePage(w, r, s.tweets, "pagination_token")
+}
+
+func (s *Server) handleUserTweets(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	var tweets []api.Tweet
+	for _, t := range s.tweets {
+		if t.AuthorID == id {
+			tweets = append(tweets, t)
+		}
+	}
+	s.writePage(w, r, tweets, "pagination_token")
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := strings.TrimSpace(r.URL.Query().Get("query"))
+	if query == "" {
+		writeProblem(w, http.StatusBadRequest, "Invalid Request",
+			"One or more parameters to your request was invalid.",
+			"https://api.twitter.com/2/problems/invalid-request")
+		return
+	}
+
+	var tweets []api.Tweet
+	for _, t := range s.tweets {
+		if s.matches(t, query) {
+			tweets = append(tweets, t)
+		}
+	}
+	s.writePage(w, r, tweets, "next_token")
+}
+
+// matches implements a small subset of the search query syntax: terms,
+// from:username, conversation_id:id and (a OR b) groups. Every term and
+// group must match.
+func (s *Server) matches(t api.Tweet, query string) bool {
+	fields := strings.Fields(query)
+	for i := 0; i < len(fields); i++ {
+		alternatives := []string{fields[i]}
+		if strings.HasPrefix(fields[i], "(") {
+			// Collect the group up to its closing parenthesis
+			group := []string{strings.TrimPrefix(fields[i], "(")}
+			for !strings.HasSuffix(group[len(group)-1], ")") && i+1 < len(fields) {
+				i++
+				group = append(group, fields[i])
+			}
+			group[len(group)-1] = strings.TrimSuffix(group[len(group)-1], ")")
+
+			alternatives = nil
+			for _, term := range group {
+				if term != "" && term != "OR" {
+					alternatives = append(alternatives, term)
+				}
+			}
+		}
+
+		if !slices.ContainsFunc(alternatives, func(term string) bool { return s.matchesTerm(t, term) }) {
+			return false
+		}
+	}
+	return true
+}
+
+// matchesTerm matches a single search term
+func (s *Server) matchesTerm(t api.Tweet, term string) bool {
+	switch {
+	case strings.HasPrefix(term, "conversation_id:"):
+		// The root of a conversation is not part of its search results
+		id := strings.TrimPrefix(term, "conversation_id:")
+		return t.ConversationID == id && t.ID != id
+	case strings.HasPrefix(term, "from:"):
+		author := s.userByID(t.AuthorID)
+		return author != nil && strings.EqualFold(author.Username, strings.TrimPrefix(term, "from:"))
+	}
+	return strings.Contains(strings.ToLower(t.Text), strings.ToLower(term))
+}
+
+// writePage writes one page of tweets, honouring max_results and the
+// pagination token parameter
+func (s *Server) writePage(w http.ResponseWriter, r *http.Request, tweets []api.Tweet, tokenParam string) {
+	pageSize := s.opts.PageSize
+	if v, err := strconv.Atoi(r.URL.Query().Get("max_results")); err == nil && v > 0 {
+		pageSize = v
+	}
+
+	offset := 0
+	if token := r.URL.Query().Get(tokenParam); token != "" {
+		var ok bool
+		if offset, ok = decodeToken(token); !ok || offset > len(tweets) {
+			writeProblem(w, http.StatusBadRequest, "Invalid Request",
+				fmt.Sprintf("The `%s` query parameter value [%s] is not valid", tokenParam, token),
+				"https://api.twitter.com/2/problems/invalid-request")
+			return
+		}
+	}
+
+	end := offset + pageSize
+	if end > len(tweets) {
+		end = len(tweets)
+	}
+	page := tweets[offset:end]
+
+	meta := &api.ResponseMeta{ResultCount: len(page)}
+	if end < len(tweets) {
+		meta.NextToken = encodeToken(end)
+	}
+	if offset > 0 {
+		meta.PreviousToken = encodeToken(max(offset-pageSize, 0))
+	}
+
+	resp := api.TimelineResponse{
+		Data:     page,
+		Includes: s.includesFor(page),
+		Meta:     meta,
+	}
+	if len(page) == 0 {
+		resp.Data = nil
+		resp.Includes = nil
+	}
+	writeJSON(w, resp)
+}
+
+// includesFor returns the expansions for a set of tweets
+func (s *Server) includesFor(tweets []api.Tweet) *api.Includes {
+	includes := &api.Includes{}
+	seenTweets := make(map[string]bool)
+	for _, t := range tweets {
+		for _, ref := range t.ReferencedTweets {
+			if seenTweets[ref.ID] {
+				continue
+			}
+			seenTweets[ref.ID] = true
+			if referenced := s.tweetByID(ref.ID); referenced != nil {
+				includes.Tweets = append(includes.Tweets, *referenced)
+			}
+		}
+	}
+
+	// Authors are expanded for referenced tweets too
+	seenUsers := make(map[string]bool)
+	for _, t := range append(slices.Clone(tweets), includes.Tweets...) {
+		if seenUsers[t.AuthorID] {
+			continue
+		}
+		seenUsers[t.AuthorID] = true
+		if u := s.userByID(t.AuthorID); u != nil {
+			includes.Users = append(includes.Users, *u)
+		}
+	}
+
+	for _, t := range tweets {
+		if t.Attachments == nil {
+			continue
+		}
+		for _, key := range t.Attachments.MediaKeys {
+			for _, m := range s.media {
+				if m.MediaKey == key {
+					includes.Media = append(includes.Media, m)
+				}
+			}
+		}
+		for _, id := range t.Attachments.PollIDs {
+			for _, p := range s.polls {
+				if p.ID == id {
+					includes.Polls = append(includes.Polls, p)
+				}
+			}
+		}
+	}
+	return includes
+}
+
+func (s *Server) tweetByID(id string) *api.Tweet {
+	for i := range s.tweets {
+		if s.tweets[i].ID == id {
+			return &s.tweets[i]
+		}
+	}
+	for i := range s.versions {
+		if s.versions[i].ID == id {
+			return &s.versions[i]
+		}
+	}
+	return nil
+}
+
+func (s *Server) userByID(id string) *api.User {
+	for i := range s.users {
+		if s.users[i].ID == id {
+			return &s.users[i]
+		}
+	}
+	return nil
+}
+
+// encodeToken makes an opaque pagination token from an offset
+func encodeToken(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+// decodeToken reverses encodeToken
+func decodeToken(token string) (int, bool) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, false
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(data), "offset:"))
+	if err != nil || offset < 0 {
+		return 0, false
+	}
+	return offset, true
+}
+
+func sortNewestFirst(tweets []api.Tweet) {
+	sort.SliceStable(tweets, func(i, j int) bool {
+		return tweets[i].CreatedAt.After(tweets[j].CreatedAt)
+	})
+}
+
+func notFound(resourceType, parameter, value, detail string) api.ResourceError {
+	return api.ResourceError{
+		Title:        "Not Found Error",
+		Detail:       detail,
+		Type:         "https://api.twitter.com/2/problems/resource-not-found",
+		ResourceType: resourceType,
+		ResourceID:   value,
+		Parameter:    parameter,
+		Value:        value,
+	}
+}
+
+func writeProblem(w http.ResponseWriter, status int, title, detail, typ string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(api.APIError{Status: status, Title: title, Detail: detail, Type: typ})
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(v)
+}
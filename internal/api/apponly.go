@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// appTokenURL is the client credentials endpoint for app-only tokens. It
+// lives outside the /2 API root.
+const appTokenURL = "https://api.twitter.com/oauth2/token"
+
+// AppTokenSource obtains an app-only bearer token from an API key and
+// secret with the client credentials grant. The token is cached until a
+// request using it is rejected.
+type AppTokenSource struct {
+	apiKey     string
+	apiSecret  string
+	tokenURL   string
+	httpClient *http.Client
+
+	mu    sync.Mutex
+	token string
+}
+
+// NewAppTokenSource creates a token source for an API key and secret
+func NewAppTokenSource(apiKey, apiSecret string) *AppTokenSource {
+	return &AppTokenSource{
+		apiKey:    apiKey,
+		apiSecret: apiSecret,
+		tokenURL:  appTokenURL,
+		// Token requests bypass any cassette, which would record the token
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// SetTokenURL points the source at a different token endpoint
+func (s *AppTokenSource) SetTokenURL(u string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokenURL = u
+	s.token = ""
+}
+
+// Token returns the cached token, requesting one first if there is none
+func (s *AppTokenSource) Token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" {
+		return s.token, nil
+	}
+
+	token, err := s.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+	return token, nil
+}
+
+// Invalidate drops token from the cache, unless it was already replaced
+func (s *AppTokenSource) Invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.token == token {
+		s.token = ""
+	}
+}
+
+// fetch requests a new token. s.mu must be held.
+func (s *AppTokenSource) fetch(ctx context.Context) (string, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded;charset=UTF-8")
+	req.SetBasicAuth(url.QueryEscape(s.apiKey), url.QueryEscape(s.apiSecret))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to get app-only token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read app-only token: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get app-only token: %w", decodeAPIError(resp.StatusCode, body))
+	}
+
+	var result struct {
+		TokenType   string `json:"token_type"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return "", fmt.Errorf("failed to parse app-only token: %w", err)
+	}
+	if !strings.EqualFold(result.TokenType, "bearer") || result.AccessToken == "" {
+		return "", fmt.Errorf("unexpected app-only token response (token_type %q)", result.TokenType)
+	}
+	return result.AccessToken, nil
+}
+
+// appOnlyTransport authenticates requests with an app-only token. On a 401
+// it invalidates the token and retries once with a fresh one.
+type appOnlyTransport struct {
+	source *AppTokenSource
+	base   http.RoundTripper
+}
+
+func (t *appOnlyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.source.Token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.send(req, token)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	t.source.Invalidate(token)
+	if req.Body != nil && req.GetBody == nil {
+		return resp, nil
+	}
+
+	fresh, err := t.source.Token(req.Context())
+	if err != nil || fresh == token {
+		return resp, nil
+	}
+	resp.Body.Close()
+	return t.send(req, fresh)
+}
+
+// send issues a copy of req with token, leaving the caller's request as is
+func (t *appOnlyTransport) send(req *http.Request, token string) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		out.Body = body
+	}
+	out.Header.Set("Authorization", "Bearer "+token)
+	return t.base.RoundTrip(out)
+}
@@ -4,25 +4,32 @@ import (
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"time"
 
 	"golang.org/x/oauth2"
 )
 
 const (
-	authURL  = "https://twitter.com/i/oauth2/authorize"
-	tokenURL = "https://api.twitter.com/2/oauth2/token"
+	authURL   = "https://twitter.com/i/oauth2/authorize"
+	tokenURL  = "https://api.twitter.com/2/oauth2/token"
+	revokeURL = "https://api.twitter.com/2/oauth2/revoke"
 )
 
-// TokenStore handles OAuth token persistence
+// TokenStore handles OAuth token persistence on top of a TokenBackend
 type TokenStore struct {
-	configPath string
+	backend TokenBackend
 }
 
 // StoredToken represents a persisted OAuth token
@@ -31,45 +38,134 @@ type StoredToken struct {
 	RefreshToken string    `json:"refresh_token"`
 	TokenType    string    `json:"token_type"`
 	Expiry       time.Time `json:"expiry"`
+	Scope        string    `json:"scope,omitempty"`
+	User         *User     `json:"user,omitempty"`
 }
 
 // NewTokenStore creates a new token store
 func NewTokenStore() *TokenStore {
+	return NewTokenStoreForAccount("")
+}
+
+// NewTokenStoreForAccount creates a plain file token store for a named
+// account
+func NewTokenStoreForAccount(account string) *TokenStore {
+	return NewTokenStoreWithBackend(NewFileBackend(TokenFilePath(account)))
+}
+
+// NewTokenStoreWithBackend creates a token store on any backend
+func NewTokenStoreWithBackend(backend TokenBackend) *TokenStore {
+	return &TokenStore{backend: backend}
+}
+
+// TokenFilePath returns the token file of an account in the home
+// directory. The default account ("" or "default") uses the original file.
+func TokenFilePath(account string) string {
 	home, _ := os.UserHomeDir()
-	return &TokenStore{
-		configPath: filepath.Join(home, ".xjson_token.json"),
+	name := ".xjson_token.json"
+	if account != "" && account != "default" {
+		name = fmt.Sprintf(".xjson_token.%s.json", account)
 	}
+	return filepath.Join(home, name)
+}
+
+// Backend returns the backend the store reads and writes
+func (ts *TokenStore) Backend() TokenBackend {
+	return ts.backend
 }
 
-// Save persists the OAuth token
+// Save persists a refreshed OAuth token, keeping the cached user since a
+// refresh belongs to the same account
 func (ts *TokenStore) Save(token *oauth2.Token) error {
-	stored := StoredToken{
+	updated := storedFromToken(token, nil)
+	if stored, err := ts.read(); err == nil {
+		updated.User = stored.User
+		if updated.Scope == "" {
+			updated.Scope = stored.Scope
+		}
+	}
+	return ts.write(updated)
+}
+
+// Replace persists a token from a new login, discarding the cached user
+func (ts *TokenStore) Replace(token *oauth2.Token) error {
+	return ts.write(storedFromToken(token, nil))
+}
+
+// SaveUser caches the authenticated user alongside the token
+func (ts *TokenStore) SaveUser(user *User) error {
+	stored, err := ts.read()
+	if err != nil {
+		return err
+	}
+	stored.User = user
+	return ts.write(*stored)
+}
+
+// LoadUser returns the cached authenticated user, if any
+func (ts *TokenStore) LoadUser() (*User, error) {
+	stored, err := ts.read()
+	if err != nil {
+		return nil, err
+	}
+	if stored.User == nil {
+		return nil, fmt.Errorf("no cached user")
+	}
+	return stored.User, nil
+}
+
+// Delete removes the stored token
+func (ts *TokenStore) Delete() error {
+	return ts.backend.Delete()
+}
+
+// LoadStored returns the stored token with the granted scopes and cached user
+func (ts *TokenStore) LoadStored() (*StoredToken, error) {
+	return ts.read()
+}
+
+func storedFromToken(token *oauth2.Token, user *User) StoredToken {
+	// The token response lists the granted scopes, space separated
+	scope, _ := token.Extra("scope").(string)
+	return StoredToken{
 		AccessToken:  token.AccessToken,
 		RefreshToken: token.RefreshToken,
 		TokenType:    token.TokenType,
 		Expiry:       token.Expiry,
+		Scope:        scope,
+		User:         user,
 	}
+}
 
+func (ts *TokenStore) read() (*StoredToken, error) {
+	data, err := ts.backend.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	var stored StoredToken
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, err
+	}
+	return &stored, nil
+}
+
+func (ts *TokenStore) write(stored StoredToken) error {
 	data, err := json.MarshalIndent(stored, "", "  ")
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(ts.configPath, data, 0600)
+	return ts.backend.Write(data)
 }
 
 // Load retrieves the stored OAuth token
 func (ts *TokenStore) Load() (*oauth2.Token, error) {
-	data, err := os.ReadFile(ts.configPath)
+	stored, err := ts.read()
 	if err != nil {
 		return nil, err
 	}
 
-	var stored StoredToken
-	if err := json.Unmarshal(data, &stored); err != nil {
-		return nil, err
-	}
-
 	return &oauth2.Token{
 		AccessToken:  stored.AccessToken,
 		RefreshToken: stored.RefreshToken,
@@ -80,8 +176,7 @@ func (ts *TokenStore) Load() (*oauth2.Token, error) {
 
 // Exists checks if a stored token exists
 func (ts *TokenStore) Exists() bool {
-	_, err := os.Stat(ts.configPath)
-	return err == nil
+	return ts.backend.Exists()
 }
 
 // generateCodeVerifier creates a PKCE code verifier
@@ -102,6 +197,7 @@ func generateCodeChallenge(verifier string) string {
 // Authenticator handles OAuth 2.0 PKCE flow
 type Authenticator struct {
 	config     *oauth2.Config
+	revokeURL  string
 	tokenStore *TokenStore
 }
 
@@ -118,6 +214,7 @@ func NewAuthenticator(clientID, clientSecret, redirectURL string) *Authenticator
 			RedirectURL: redirectURL,
 			Scopes:      []string{"tweet.read", "users.read", "offline.access"},
 		},
+		revokeURL:  revokeURL,
 		tokenStore: NewTokenStore(),
 	}
 }
@@ -126,7 +223,7 @@ func NewAuthenticator(clientID, clientSecret, redirectURL string) *Authenticator
 func (a *Authenticator) GetToken(ctx context.Context) (*oauth2.Token, error) {
 	token, err := a.tokenStore.Load()
 	if err != nil {
-		return nil, fmt.Errorf("no stored token: %w", err)
+		return nil, fmt.Errorf("failed to load token: %w", err)
 	}
 
 	// Check if token needs refresh
@@ -145,21 +242,85 @@ func (a *Authenticator) GetToken(ctx context.Context) (*oauth2.Token, error) {
 	return token, nil
 }
 
-// StartAuthFlow initiates the OAuth flow and returns the auth URL
-func (a *Authenticator) StartAuthFlow() (authURL string, verifier string, err error) {
-	verifier, err = generateCodeVerifier()
+// AuthFlow is an authorization in progress
+type AuthFlow struct {
+	URL      string // authorization URL to open in the browser
+	Verifier string // PKCE code verifier for CompleteAuthFlow
+	State    string // random state the callback must echo back
+}
+
+// ErrStateMismatch is returned when a callback's state doesn't match the flow
+var ErrStateMismatch = errors.New("OAuth state mismatch: the callback does not belong to this login attempt")
+
+// ErrNoCode is returned when a callback carries neither a code nor an error
+var ErrNoCode = errors.New("no authorization code in callback")
+
+// CallbackError is an error redirect from the authorization server, e.g.
+// when the user denies access
+type CallbackError struct {
+	Code        string // OAuth error code such as "access_denied"
+	Description string
+}
+
+func (e *CallbackError) Error() string {
+	msg := fmt.Sprintf("authorization failed: %s", e.Code)
+	if e.Denied() {
+		msg = "authorization was denied in the browser"
+	}
+	if e.Description != "" {
+		msg += " (" + e.Description + ")"
+	}
+	return msg
+}
+
+// Denied reports whether the user declined to authorize the app
+func (e *CallbackError) Denied() bool {
+	return e.Code == "access_denied"
+}
+
+// ParseCallback validates the callback's query parameters against the flow
+// and returns the authorization code
+func (f *AuthFlow) ParseCallback(query url.Values) (string, error) {
+	state := query.Get("state")
+	if subtle.ConstantTimeCompare([]byte(state), []byte(f.State)) != 1 {
+		return "", ErrStateMismatch
+	}
+
+	if code := query.Get("error"); code != "" {
+		return "", &CallbackError{
+			Code:        code,
+			Description: query.Get("error_description"),
+		}
+	}
+
+	code := query.Get("code")
+	if code == "" {
+		return "", ErrNoCode
+	}
+	return code, nil
+}
+
+// StartAuthFlow initiates the OAuth flow with a fresh verifier and state
+func (a *Authenticator) StartAuthFlow() (*AuthFlow, error) {
+	verifier, err := generateCodeVerifier()
 	if err != nil {
-		return "", "", err
+		return nil, err
+	}
+
+	// The verifier generator doubles as a source of random state
+	state, err := generateCodeVerifier()
+	if err != nil {
+		return nil, err
 	}
 
 	challenge := generateCodeChallenge(verifier)
 
-	authURL = a.config.AuthCodeURL("state",
+	authURL := a.config.AuthCodeURL(state,
 		oauth2.SetAuthURLParam("code_challenge", challenge),
 		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
 	)
 
-	return authURL, verifier, nil
+	return &AuthFlow{URL: authURL, Verifier: verifier, State: state}, nil
 }
 
 // CompleteAuthFlow exchanges the auth code for a token
@@ -171,23 +332,168 @@ func (a *Authenticator) CompleteAuthFlow(ctx context.Context, code, verifier str
 		return nil, err
 	}
 
-	if err := a.tokenStore.Save(token); err != nil {
+	if err := a.tokenStore.Replace(token); err != nil {
 		return nil, fmt.Errorf("failed to save token: %w", err)
 	}
 
 	return token, nil
 }
 
+// SetTokenStore replaces the store tokens are loaded from and saved to
+func (a *Authenticator) SetTokenStore(ts *TokenStore) {
+	a.tokenStore = ts
+}
+
+// TokenStore returns the store backing this authenticator
+func (a *Authenticator) TokenStore() *TokenStore {
+	return a.tokenStore
+}
+
+// SetEndpoint points the authenticator at a different authorization
+// server, such as a local fake in tests. The revocation endpoint is taken
+// to be next to the token endpoint, as it is on X.
+func (a *Authenticator) SetEndpoint(authURL, tokenURL string) {
+	a.config.Endpoint = oauth2.Endpoint{AuthURL: authURL, TokenURL: tokenURL}
+	a.revokeURL = strings.TrimSuffix(tokenURL, "/token") + "/revoke"
+}
+
+// Revoke invalidates a token's refresh and access tokens at the
+// authorization server
+func (a *Authenticator) Revoke(ctx context.Context, token *oauth2.Token) error {
+	var errs []error
+	if token.RefreshToken != "" {
+		errs = append(errs, a.revoke(ctx, token.RefreshToken, "refresh_token"))
+	}
+	if token.AccessToken != "" {
+		errs = append(errs, a.revoke(ctx, token.AccessToken, "access_token"))
+	}
+	return errors.Join(errs...)
+}
+
+// revoke sends one token to the revocation endpoint
+func (a *Authenticator) revoke(ctx context.Context, token, hint string) error {
+	form := url.Values{
+		"token":           {token},
+		"token_type_hint": {hint},
+		"client_id":       {a.config.ClientID},
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.revokeURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if a.config.ClientSecret != "" {
+		// Confidential clients authenticate like they do at the token endpoint
+		req.SetBasicAuth(url.QueryEscape(a.config.ClientID), url.QueryEscape(a.config.ClientSecret))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to revoke %s: %w", hint, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("failed to revoke %s: status %d: %s", hint, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// Logout revokes the stored token and deletes it. The stored copy is
+// deleted even when revocation fails, and the revocation error is returned.
+func (a *Authenticator) Logout(ctx context.Context) error {
+	token, err := a.tokenStore.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load token: %w", err)
+	}
+
+	revokeErr := a.Revoke(ctx, token)
+	if err := a.tokenStore.Delete(); err != nil {
+		return errors.Join(revokeErr, fmt.Errorf("failed to delete token: %w", err))
+	}
+	return revokeErr
+}
+
+// Authorize runs the browser flow end to end: it starts a callback server on
+// the redirect URL, passes the authorization URL to open, waits for the
+// callback until ctx is done, and exchanges and stores the token
+func (a *Authenticator) Authorize(ctx context.Context, open func(authURL string)) (*oauth2.Token, error) {
+	flow, err := a.StartAuthFlow()
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := NewCallbackServer(a.config.RedirectURL, flow)
+	if err != nil {
+		return nil, err
+	}
+	defer server.Close()
+
+	open(flow.URL)
+
+	code, err := server.Wait(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	exchangeCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	return a.CompleteAuthFlow(exchangeCtx, code, flow.Verifier)
+}
+
 // HasStoredToken checks if there's a valid stored token
 func (a *Authenticator) HasStoredToken() bool {
 	return a.tokenStore.Exists()
 }
 
-// HTTPClient returns an HTTP client with the OAuth token
+// HTTPClient returns an HTTP client with the OAuth token. Tokens refreshed
+// during the session are written back to the token store.
 func (a *Authenticator) HTTPClient(ctx context.Context) (*http.Client, error) {
 	token, err := a.GetToken(ctx)
 	if err != nil {
 		return nil, err
 	}
-	return a.config.Client(ctx, token), nil
+	return oauth2.NewClient(ctx, a.TokenSource(ctx, token)), nil
+}
+
+// TokenSource returns a token source that refreshes token as needed and
+// persists every new token it hands out
+func (a *Authenticator) TokenSource(ctx context.Context, token *oauth2.Token) oauth2.TokenSource {
+	return &savingTokenSource{
+		base:  a.config.TokenSource(ctx, token),
+		store: a.tokenStore,
+		last:  token,
+	}
+}
+
+// savingTokenSource saves tokens from base whenever they change. X issues
+// one-time refresh tokens, so a refresh that isn't persisted would leave
+// the next launch with a refresh token that no longer works.
+type savingTokenSource struct {
+	mu    sync.Mutex
+	base  oauth2.TokenSource
+	store *TokenStore
+	last  *oauth2.Token
+}
+
+// Token implements oauth2.TokenSource
+func (s *savingTokenSource) Token() (*oauth2.Token, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, err := s.base.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if s.last == nil || token.AccessToken != s.last.AccessToken || token.RefreshToken != s.last.RefreshToken {
+		// On failure, keep the old token as last so the save is retried on
+		// the next call rather than failing a request that has a valid token
+		if err := s.store.Save(token); err == nil {
+			s.last = token
+		}
+	}
+
+	return token, nil
 }
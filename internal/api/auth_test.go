@@ -0,0 +1,75 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kenan/xjson/internal/api"
+	"github.com/kenan/xjson/internal/api/apitest"
+	"golang.org/x/oauth2"
+)
+
+// newTestAuthenticator returns an authenticator against an authorization
+// server at baseURL, storing tokens in memory
+func newTestAuthenticator(baseURL string) *api.Authenticator {
+	auth := api.NewAuthenticator("client", "", "http://127.0.0.1:8080/callback")
+	auth.SetEndpoint(baseURL+"/authorize", baseURL+"/2/oauth2/token")
+	auth.SetTokenStore(api.NewTokenStoreWithBackend(api.NewKeyringBackend(apitest.NewSecretService(), "test")))
+	return auth
+}
+
+func TestLogoutRevokesTokens(t *testing.T) {
+	fake := apitest.NewServer(apitest.Options{})
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	ctx := context.Background()
+	auth := newTestAuthenticator(srv.URL)
+	token, err := auth.CompleteAuthFlow(ctx, "auth-code", "verifier")
+	if err != nil {
+		t.Fatalf("CompleteAuthFlow: %v", err)
+	}
+
+	if err := auth.Logout(ctx); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if !fake.Revoked(token.AccessToken) {
+		t.Error("access token was not revoked")
+	}
+	if !fake.Revoked(token.RefreshToken) {
+		t.Error("refresh token was not revoked")
+	}
+	if auth.HasStoredToken() {
+		t.Error("token is still stored")
+	}
+
+	client := api.NewClient(oauth2.NewClient(ctx, oauth2.StaticTokenSource(token)))
+	client.SetBaseURL(srv.URL + "/2")
+	_, err = client.GetMe(ctx)
+	var apiErr *api.APIError
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusUnauthorized {
+		t.Fatalf("GetMe with revoked token: %v, want a 401", err)
+	}
+}
+
+func TestLogoutDeletesTokenWhenRevokeFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	auth := newTestAuthenticator(srv.URL)
+	if err := auth.TokenStore().Replace(&oauth2.Token{AccessToken: "access", RefreshToken: "refresh"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := auth.Logout(context.Background()); err == nil {
+		t.Fatal("Logout succeeded although revocation failed")
+	}
+	if auth.HasStoredToken() {
+		t.Error("token is still stored after a failed revocation")
+	}
+}
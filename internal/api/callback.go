@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const callbackSuccessPage = `
+<html><body style="font-family: monospace; padding: 40px; background: #1a1a2e; color: #0f0;">
+<h2>Authorization successful!</h2>
+<p>You can close this window and return to the terminal.</p>
+</body></html>
+`
+
+const callbackErrorPage = `
+<html><body style="font-family: monospace; padding: 40px; background: #1a1a2e; color: #f55;">
+<h2>Authorization failed</h2>
+<p>%s</p>
+</body></html>
+`
+
+// callbackResult is the outcome of the first callback request for the flow
+type callbackResult struct {
+	code string
+	err  error
+}
+
+// CallbackServer receives the OAuth redirect for one AuthFlow. It listens
+// only on the loopback host and port of the redirect URL, on its own mux.
+type CallbackServer struct {
+	server    *http.Server
+	listeners []net.Listener
+	results   chan callbackResult
+	closeOnce sync.Once
+}
+
+// NewCallbackServer binds the host, port and path of redirectURL and starts
+// serving callbacks for flow. The host must be localhost or a loopback IP.
+func NewCallbackServer(redirectURL string, flow *AuthFlow) (*CallbackServer, error) {
+	u, err := url.Parse(redirectURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect URL: %w", err)
+	}
+	if u.Scheme != "http" {
+		return nil, fmt.Errorf("redirect URL %q must use http on a loopback address", redirectURL)
+	}
+
+	hosts, err := loopbackHosts(u.Hostname())
+	if err != nil {
+		return nil, err
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+	}
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+
+	s := &CallbackServer{results: make(chan callbackResult, 1)}
+
+	for i, host := range hosts {
+		ln, err := net.Listen("tcp", net.JoinHostPort(host, port))
+		if err != nil {
+			if i == 0 {
+				return nil, fmt.Errorf("failed to listen for auth callback: %w", err)
+			}
+			// The IPv6 loopback for localhost is best effort
+			continue
+		}
+		s.listeners = append(s.listeners, ln)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+		code, err := flow.ParseCallback(r.URL.Query())
+		// A request without this flow's state isn't the redirect being
+		// waited for, so it doesn't end the login
+		if !errors.Is(err, ErrStateMismatch) {
+			s.deliver(callbackResult{code: code, err: err})
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintf(w, callbackErrorPage, html.EscapeString(err.Error()))
+			return
+		}
+		fmt.Fprint(w, callbackSuccessPage)
+	})
+
+	s.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	for _, ln := range s.listeners {
+		go func(ln net.Listener) {
+			if err := s.server.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				s.deliver(callbackResult{err: fmt.Errorf("auth callback server: %w", err)})
+			}
+		}(ln)
+	}
+
+	return s, nil
+}
+
+// loopbackHosts returns the addresses to bind for a redirect host; the
+// first one is required
+func loopbackHosts(host string) ([]string, error) {
+	if host == "localhost" {
+		return []string{"127.0.0.1", "::1"}, nil
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.IsLoopback() {
+		return []string{host}, nil
+	}
+	return nil, fmt.Errorf("redirect URL host %q is not a loopback address", host)
+}
+
+// deliver records the first result; later callbacks are ignored
+func (s *CallbackServer) deliver(result callbackResult) {
+	select {
+	case s.results <- result:
+	default:
+	}
+}
+
+// Addr returns the address the server is listening on
+func (s *CallbackServer) Addr() string {
+	return s.listeners[0].Addr().String()
+}
+
+// Wait blocks until a callback for the flow arrives or ctx is done, and
+// returns the authorization code
+func (s *CallbackServer) Wait(ctx context.Context) (string, error) {
+	select {
+	case result := <-s.results:
+		return result.code, result.err
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+}
+
+// Close shuts the server down, giving the callback response time to finish
+func (s *CallbackServer) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err = s.server.Shutdown(ctx)
+	})
+	return err
+}
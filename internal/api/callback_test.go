@@ -0,0 +1,166 @@
+package api_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/kenan/xjson/internal/api"
+	"github.com/kenan/xjson/internal/api/apitest"
+)
+
+// startCallbackServer starts a callback server for a new flow on an
+// ephemeral loopback port
+func startCallbackServer(t *testing.T) (*api.CallbackServer, *api.AuthFlow) {
+	t.Helper()
+	flow, err := api.NewAuthenticator("client", "", "http://127.0.0.1:0/callback").StartAuthFlow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := api.NewCallbackServer("http://127.0.0.1:0/callback", flow)
+	if err != nil {
+		t.Fatalf("NewCallbackServer: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server, flow
+}
+
+// callback sends a redirect to the server and returns the response status
+func callback(t *testing.T, server *api.CallbackServer, query url.Values) int {
+	t.Helper()
+	resp, err := http.Get("http://" + server.Addr() + "/callback?" + query.Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	return resp.StatusCode
+}
+
+// wait waits briefly for the server's result
+func wait(server *api.CallbackServer) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	return server.Wait(ctx)
+}
+
+func TestCallbackServerCode(t *testing.T) {
+	server, flow := startCallbackServer(t)
+
+	status := callback(t, server, url.Values{"state": {flow.State}, "code": {"auth-code"}})
+	if status != http.StatusOK {
+		t.Fatalf("status = %d, want 200", status)
+	}
+	code, err := wait(server)
+	if err != nil || code != "auth-code" {
+		t.Fatalf("Wait = %q, %v, want auth-code", code, err)
+	}
+}
+
+func TestCallbackServerIgnoresWrongState(t *testing.T) {
+	server, flow := startCallbackServer(t)
+
+	status := callback(t, server, url.Values{"state": {"not-the-state"}, "code": {"stray"}})
+	if status != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", status)
+	}
+	if _, err := wait(server); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait error = %v, want the login to keep waiting", err)
+	}
+
+	callback(t, server, url.Values{"state": {flow.State}, "code": {"auth-code"}})
+	if code, err := wait(server); err != nil || code != "auth-code" {
+		t.Fatalf("Wait = %q, %v, want auth-code", code, err)
+	}
+}
+
+func TestCallbackServerDenied(t *testing.T) {
+	server, flow := startCallbackServer(t)
+
+	status := callback(t, server, url.Values{
+		"state":             {flow.State},
+		"error":             {"access_denied"},
+		"error_description": {"The user denied access"},
+	})
+	if status != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", status)
+	}
+
+	_, err := wait(server)
+	var callbackErr *api.CallbackError
+	if !errors.As(err, &callbackErr) || !callbackErr.Denied() {
+		t.Fatalf("Wait error = %v, want a denied CallbackError", err)
+	}
+}
+
+func TestCallbackServerRejectsNonLoopback(t *testing.T) {
+	flow := &api.AuthFlow{State: "state"}
+	for _, redirect := range []string{
+		"http://example.com:8080/callback",
+		"http://0.0.0.0:8080/callback",
+		"http://192.168.1.10:8080/callback",
+		"https://127.0.0.1:8080/callback",
+	} {
+		if server, err := api.NewCallbackServer(redirect, flow); err == nil {
+			server.Close()
+			t.Errorf("NewCallbackServer(%q) was accepted", redirect)
+		}
+	}
+}
+
+// freePort returns a loopback port that was free a moment ago
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestAuthorize(t *testing.T) {
+	fake := httptest.NewServer(apitest.NewServer(apitest.Options{}))
+	defer fake.Close()
+
+	redirect := "http://127.0.0.1:" + strconv.Itoa(freePort(t)) + "/callback"
+	auth := api.NewAuthenticator("client", "", redirect)
+	auth.SetEndpoint(fake.URL+"/authorize", fake.URL+"/2/oauth2/token")
+	auth.SetTokenStore(api.NewTokenStoreWithBackend(api.NewKeyringBackend(apitest.NewSecretService(), "test")))
+
+	// Stand in for the browser: approve and follow the redirect
+	open := func(authURL string) {
+		u, err := url.Parse(authURL)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		query := url.Values{"state": {u.Query().Get("state")}, "code": {"auth-code"}}
+		go func() {
+			resp, err := http.Get(u.Query().Get("redirect_uri") + "?" + query.Encode())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.Body.Close()
+		}()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	token, err := auth.Authorize(ctx, open)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	if token.AccessToken == "" || token.RefreshToken == "" {
+		t.Fatalf("Authorize returned an incomplete token: %+v", token)
+	}
+	if !auth.HasStoredToken() {
+		t.Fatal("token was not stored")
+	}
+}
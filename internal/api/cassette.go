@@ -0,0 +1,357 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Environment variables that turn on recording or replaying of API traffic
+const (
+	EnvRecord     = "XJSON_RECORD"
+	EnvReplay     = "XJSON_REPLAY"
+	EnvReplayMode = "XJSON_REPLAY_MODE"
+)
+
+// sensitiveParams are query parameters never written to a cassette
+var sensitiveParams = []string{"access_token", "refresh_token", "client_secret", "token", "code"}
+
+// sensitiveHeaders are response headers never written to a cassette
+var sensitiveHeaders = []string{"Set-Cookie", "Authorization"}
+
+// Cassette is a recorded API session
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is one recorded request/response pair
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+// RecordedRequest identifies a request. Headers are not recorded, so
+// credentials never end up on disk.
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+// RecordedResponse is a response as it came off the wire
+type RecordedResponse struct {
+	Status  int         `json:"status"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body"`
+}
+
+// LoadCassette reads a cassette file
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read cassette: %w", err)
+	}
+
+	var c Cassette
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette: %w", err)
+	}
+	return &c, nil
+}
+
+// Save writes the cassette to a file
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// Recorder is an http.RoundTripper that passes requests through to base
+// and appends every exchange to a cassette file
+type Recorder struct {
+	base http.RoundTripper
+	tape *recording
+}
+
+// recording is a cassette being written, shared by the recorders made
+// from one another with WithBase
+type recording struct {
+	path string
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder creates a recorder writing a new cassette to path
+func NewRecorder(path string, base http.RoundTripper) *Recorder {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Recorder{base: base, tape: &recording{path: path}}
+}
+
+// WithBase returns a recorder that writes to the same cassette but sends
+// requests through base, e.g. with another account's credentials
+func (r *Recorder) WithBase(base http.RoundTripper) *Recorder {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Recorder{base: base, tape: r.tape}
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := r.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	headers := resp.Header.Clone()
+	for _, h := range sensitiveHeaders {
+		headers.Del(h)
+	}
+
+	tape := r.tape
+	tape.mu.Lock()
+	defer tape.mu.Unlock()
+
+	tape.cassette.Interactions = append(tape.cassette.Interactions, Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    redactURL(req.URL),
+		},
+		Response: RecordedResponse{
+			Status:  resp.StatusCode,
+			Headers: headers,
+			Body:    string(body),
+		},
+	})
+
+	// Save after every exchange so an interrupted session still leaves a cassette
+	if err := tape.cassette.Save(tape.path); err != nil {
+		return nil, fmt.Errorf("failed to save cassette: %w", err)
+	}
+
+	return resp, nil
+}
+
+// MatchMode controls how a Replayer pairs requests with interactions
+type MatchMode int
+
+const (
+	// MatchLenient matches on method and path, preferring an exact URL and
+	// unused interactions, and replays the last match again when all are used
+	MatchLenient MatchMode = iota
+	// MatchStrict requires requests to arrive in recorded order with the
+	// exact method and URL
+	MatchStrict
+)
+
+// ParseMatchMode parses "strict" or "lenient"
+func ParseMatchMode(s string) (MatchMode, error) {
+	switch strings.ToLower(s) {
+	case "", "lenient":
+		return MatchLenient, nil
+	case "strict":
+		return MatchStrict, nil
+	}
+	return MatchLenient, fmt.Errorf("unknown replay mode %q (want strict or lenient)", s)
+}
+
+// Replayer is an http.RoundTripper that answers requests from a cassette
+// without touching the network
+type Replayer struct {
+	mode MatchMode
+
+	mu       sync.Mutex
+	cassette *Cassette
+	used     []bool
+	next     int
+}
+
+// NewReplayer creates a replayer for a cassette
+func NewReplayer(cassette *Cassette, mode MatchMode) *Replayer {
+	return &Replayer{
+		mode:     mode,
+		cassette: cassette,
+		used:     make([]bool, len(cassette.Interactions)),
+	}
+}
+
+// RoundTrip implements http.RoundTripper
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reqURL := redactURL(req.URL)
+	idx := -1
+	if r.mode == MatchStrict {
+		if r.next < len(r.cassette.Interactions) {
+			rec := r.cassette.Interactions[r.next].Request
+			if rec.Method == req.Method && rec.URL == reqURL {
+				idx = r.next
+				r.next++
+			}
+		}
+	} else {
+		idx = r.findLenient(req.Method, reqURL)
+	}
+
+	if idx < 0 {
+		return nil, fmt.Errorf("cassette: no recorded response for %s %s", req.Method, reqURL)
+	}
+	r.used[idx] = true
+
+	rec := r.cassette.Interactions[idx].Response
+	return &http.Response{
+		StatusCode:    rec.Status,
+		Status:        fmt.Sprintf("%d %s", rec.Status, http.StatusText(rec.Status)),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.Headers.Clone(),
+		Body:          io.NopCloser(strings.NewReader(rec.Body)),
+		ContentLength: int64(len(rec.Body)),
+		Request:       req,
+	}, nil
+}
+
+// findLenient picks the best interaction for a request, or -1
+func (r *Replayer) findLenient(method, reqURL string) int {
+	path := pathOf(reqURL)
+	exact, samePath, lastUsed := -1, -1, -1
+
+	for i, in := range r.cassette.Interactions {
+		if in.Request.Method != method || pathOf(in.Request.URL) != path {
+			continue
+		}
+		if r.used[i] {
+			lastUsed = i
+			continue
+		}
+		if in.Request.URL == reqURL && exact < 0 {
+			exact = i
+		}
+		if samePath < 0 {
+			samePath = i
+		}
+	}
+
+	switch {
+	case exact >= 0:
+		return exact
+	case samePath >= 0:
+		return samePath
+	default:
+		return lastUsed
+	}
+}
+
+// failingTransport reports a setup error on every request
+type failingTransport struct {
+	err error
+}
+
+func (t failingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, t.err
+}
+
+// wrapTransportFromEnv wraps base in a Recorder or Replayer when the
+// XJSON_RECORD or XJSON_REPLAY environment variables are set
+func wrapTransportFromEnv(base http.RoundTripper) http.RoundTripper {
+	if path := os.Getenv(EnvReplay); path != "" {
+		mode, err := ParseMatchMode(os.Getenv(EnvReplayMode))
+		if err != nil {
+			return failingTransport{err: err}
+		}
+		cassette, err := LoadCassette(path)
+		if err != nil {
+			return failingTransport{err: err}
+		}
+		return NewReplayer(cassette, mode)
+	}
+
+	if path := os.Getenv(EnvRecord); path != "" {
+		return envRecorder(path, base)
+	}
+
+	return base
+}
+
+// The recorder of the XJSON_RECORD session. A process records one session,
+// so the clients of every account append to the same cassette.
+var (
+	envRecorderMu sync.Mutex
+	envRecording  *Recorder
+)
+
+// envRecorder returns a recorder for base that writes to the session's
+// cassette at path
+func envRecorder(path string, base http.RoundTripper) *Recorder {
+	envRecorderMu.Lock()
+	defer envRecorderMu.Unlock()
+
+	if envRecording == nil || envRecording.tape.path != path {
+		envRecording = NewRecorder(path, base)
+		return envRecording
+	}
+	return envRecording.WithBase(base)
+}
+
+// withCassetteFromEnv returns httpClient with its transport wrapped by
+// wrapTransportFromEnv, leaving the caller's client untouched
+func withCassetteFromEnv(httpClient *http.Client) *http.Client {
+	if os.Getenv(EnvRecord) == "" && os.Getenv(EnvReplay) == "" {
+		return httpClient
+	}
+
+	wrapped := *httpClient
+	base := wrapped.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	wrapped.Transport = wrapTransportFromEnv(base)
+	return &wrapped
+}
+
+// redactURL returns the URL with credentials removed and the query sorted
+func redactURL(u *url.URL) string {
+	clean := *u
+	clean.User = nil
+
+	query := clean.Query()
+	for _, p := range sensitiveParams {
+		if query.Has(p) {
+			query.Set(p, "REDACTED")
+		}
+	}
+	clean.RawQuery = query.Encode()
+
+	return clean.String()
+}
+
+// pathOf returns the URL without its query
+func pathOf(rawURL string) string {
+	if i := strings.IndexByte(rawURL, '?'); i >= 0 {
+		return rawURL[:i]
+	}
+	return rawURL
+}
@@ -0,0 +1,150 @@
+package api_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kenan/xjson/internal/api"
+	"github.com/kenan/xjson/internal/api/apitest"
+	"golang.org/x/oauth2"
+)
+
+// cassetteClient returns an API client sending requests through transport
+func cassetteClient(transport http.RoundTripper, baseURL string) *api.Client {
+	client := api.NewClient(&http.Client{Transport: transport})
+	client.SetBaseURL(baseURL)
+	client.SetRetryPolicy(api.RetryPolicy{MaxAttempts: 1})
+	return client
+}
+
+func TestCassetteRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(apitest.NewServer(apitest.Options{}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "session.json")
+	const secret = "fake-access-secret"
+
+	// Record a session authenticated with a bearer token
+	auth := &oauth2.Transport{Source: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: secret})}
+	recorder := api.NewRecorder(path, auth)
+	live := cassetteClient(recorder, srv.URL+"/2")
+	if _, err := live.GetMe(ctx); err != nil {
+		t.Fatalf("GetMe: %v", err)
+	}
+	if _, err := live.SearchTweets(ctx, "go", 10, ""); err != nil {
+		t.Fatalf("SearchTweets: %v", err)
+	}
+	resp, err := (&http.Client{Transport: recorder}).Get(srv.URL + "/2/users/me?access_token=" + secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(raw), secret) {
+		t.Errorf("cassette holds the access token:\n%s", raw)
+	}
+	if strings.Contains(string(raw), "Authorization") {
+		t.Errorf("cassette holds an Authorization header:\n%s", raw)
+	}
+	if !strings.Contains(string(raw), "access_token=REDACTED") {
+		t.Errorf("token query parameter was not redacted:\n%s", raw)
+	}
+
+	cassette, err := api.LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Strict replay serves the recorded requests in order, and nothing else
+	strict := cassetteClient(api.NewReplayer(cassette, api.MatchStrict), srv.URL+"/2")
+	if _, err := strict.GetMe(ctx); err != nil {
+		t.Fatalf("strict GetMe: %v", err)
+	}
+	if _, err := strict.SearchTweets(ctx, "rust", 10, ""); err == nil || !strings.Contains(err.Error(), "no recorded response") {
+		t.Fatalf("strict SearchTweets with another query: %v, want no recorded response", err)
+	}
+
+	// Lenient replay answers the same path from the recorded search
+	lenient := cassetteClient(api.NewReplayer(cassette, api.MatchLenient), srv.URL+"/2")
+	result, err := lenient.SearchTweets(ctx, "rust", 10, "")
+	if err != nil {
+		t.Fatalf("lenient SearchTweets: %v", err)
+	}
+	if len(result.Data) == 0 {
+		t.Fatal("lenient SearchTweets returned no tweets")
+	}
+}
+
+func TestRecordersShareCassette(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	defer upstream.Close()
+
+	// One recorder per account, as when several accounts are configured
+	path := filepath.Join(t.TempDir(), "session.json")
+	recorder := api.NewRecorder(path, nil)
+	work := &http.Client{Transport: recorder}
+	home := &http.Client{Transport: recorder.WithBase(nil)}
+
+	for _, req := range []struct {
+		client *http.Client
+		path   string
+	}{
+		{work, "/2/users/me"},
+		{home, "/2/users/me?account=home"},
+		{work, "/2/tweets/search/recent?query=go"},
+	} {
+		resp, err := req.client.Get(upstream.URL + req.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	cassette, err := api.LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cassette.Interactions) != 3 {
+		t.Fatalf("cassette has %d interactions, want 3", len(cassette.Interactions))
+	}
+	if !strings.Contains(cassette.Interactions[1].Request.URL, "account=home") {
+		t.Fatalf("second interaction = %s, want the home account's request", cassette.Interactions[1].Request.URL)
+	}
+}
+
+func TestNewRecorderStartsFresh(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"data":{}}`))
+	}))
+	defer upstream.Close()
+
+	path := filepath.Join(t.TempDir(), "session.json")
+	for _, query := range []string{"run=first", "run=second"} {
+		client := &http.Client{Transport: api.NewRecorder(path, nil)}
+		resp, err := client.Get(upstream.URL + "/2/users/me?" + query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	cassette, err := api.LoadCassette(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cassette.Interactions) != 1 || !strings.HasSuffix(cassette.Interactions[0].Request.URL, "?run=second") {
+		t.Fatalf("cassette = %+v, want only the second recorder's request", cassette.Interactions)
+	}
+}
@@ -3,27 +3,78 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 )
 
-const baseURL = "https://api.twitter.com/2"
+// DefaultBaseURL is the root of the X API v2
+const DefaultBaseURL = "https://api.twitter.com/2"
+
+// tweetFields are the tweet fields requested on every tweet lookup
+const tweetFields = "created_at,public_metrics,author_id,conversation_id,in_reply_to_user_id,referenced_tweets,attachments,entities,note_tweet,edit_history_tweet_ids,edit_controls"
+
+// tweetExpansions are the objects expanded into Includes on tweet lookups
+const tweetExpansions = "author_id,attachments.media_keys,attachments.poll_ids,referenced_tweets.id,referenced_tweets.id.author_id"
+
+// mediaFields and pollFields are requested for expanded media and polls
+const (
+	mediaFields = "type,url,preview_image_url,width,height,duration_ms,alt_text,variants"
+	pollFields  = "options,duration_minutes,end_datetime,voting_status"
+)
+
+// setExpansions requests the expanded objects of tweet lookups
+func setExpansions(params url.Values) {
+	params.Set("expansions", tweetExpansions)
+	params.Set("media.fields", mediaFields)
+	params.Set("poll.fields", pollFields)
+}
 
 // Client is the X API client
 type Client struct {
 	httpClient *http.Client
 	baseURL    string
+	rateLimits *rateLimiter
+	retry      RetryPolicy
+	appTokens  *AppTokenSource
+	appOnly    bool
+
+	meMu      sync.Mutex
+	me        *User
+	userCache UserCache
+}
+
+// Capabilities describes what a client's credentials allow
+type Capabilities struct {
+	// UserContext is set for OAuth 2.0 user tokens. App-only tokens can
+	// read public data but have no home timeline or /users/me.
+	UserContext bool
 }
 
-// NewClient creates a new X API client
+// ErrUserContextRequired is returned for requests that need a signed-in
+// user when the client only has app-only auth
+var ErrUserContextRequired = errors.New("this needs a signed-in user; app-only auth can only read public tweets and profiles")
+
+// UserCache persists the authenticated user between sessions
+type UserCache interface {
+	LoadUser() (*User, error)
+	SaveUser(user *User) error
+}
+
+// NewClient creates a new X API client. Traffic is recorded to or replayed
+// from a cassette when XJSON_RECORD or XJSON_REPLAY is set.
 func NewClient(httpClient *http.Client) *Client {
 	return &Client{
-		httpClient: httpClient,
-		baseURL:    baseURL,
+		httpClient: withCassetteFromEnv(httpClient),
+		baseURL:    DefaultBaseURL,
+		rateLimits: newRateLimiter(),
+		retry:      DefaultRetryPolicy(),
 	}
 }
 
@@ -32,15 +83,51 @@ func NewClientWithBearerToken(bearerToken string) *Client {
 	return &Client{
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
-			Transport: &bearerTransport{
+			Transport: wrapTransportFromEnv(&bearerTransport{
 				token: bearerToken,
 				base:  http.DefaultTransport,
-			},
+			}),
 		},
-		baseURL: baseURL,
+		baseURL:    DefaultBaseURL,
+		rateLimits: newRateLimiter(),
+		retry:      DefaultRetryPolicy(),
+		appOnly:    true,
 	}
 }
 
+// NewClientWithAppCredentials creates a client with an app-only token
+// obtained from an API key and secret
+func NewClientWithAppCredentials(apiKey, apiSecret string) *Client {
+	source := NewAppTokenSource(apiKey, apiSecret)
+	return &Client{
+		httpClient: &http.Client{
+			Timeout: 30 * time.Second,
+			Transport: wrapTransportFromEnv(&appOnlyTransport{
+				source: source,
+				base:   http.DefaultTransport,
+			}),
+		},
+		baseURL:    DefaultBaseURL,
+		rateLimits: newRateLimiter(),
+		retry:      DefaultRetryPolicy(),
+		appTokens:  source,
+		appOnly:    true,
+	}
+}
+
+// Capabilities reports what the client's credentials allow
+func (c *Client) Capabilities() Capabilities {
+	return Capabilities{UserContext: !c.appOnly}
+}
+
+// SetUserCache makes the client load and store the authenticated user
+// through cache, so /users/me is only called once per token
+func (c *Client) SetUserCache(cache UserCache) {
+	c.meMu.Lock()
+	defer c.meMu.Unlock()
+	c.userCache = cache
+}
+
 type bearerTransport struct {
 	token string
 	base  http.RoundTripper
@@ -53,7 +140,9 @@ func (t *bearerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
 
 // RateLimitError represents a rate limit response
 type RateLimitError struct {
+	Endpoint   string
 	RetryAfter int
+	Reset      time.Time
 	Message    string
 }
 
@@ -61,13 +150,77 @@ func (e *RateLimitError) Error() string {
 	return e.Message
 }
 
-// doRequest performs an HTTP request and decodes the response
-func (c *Client) doRequest(ctx context.Context, method, path string, params url.Values, result interface{}) error {
+// RateLimit returns the last known rate limit for an endpoint template,
+// e.g. api.EndpointHomeTimeline
+func (c *Client) RateLimit(endpoint string) (RateLimit, bool) {
+	return c.rateLimits.get(endpoint)
+}
+
+// SetBaseURL points the client at a different API root, such as a local
+// fake server. It must be called before the client is used for requests.
+// App-only tokens are then requested from the same host.
+func (c *Client) SetBaseURL(u string) {
+	c.baseURL = strings.TrimSuffix(u, "/")
+	if c.appTokens != nil {
+		c.appTokens.SetTokenURL(strings.TrimSuffix(c.baseURL, "/2") + "/oauth2/token")
+	}
+}
+
+// RetryPolicy returns the client's retry policy
+func (c *Client) RetryPolicy() RetryPolicy {
+	return c.retry
+}
+
+// SetRetryPolicy replaces the client's retry policy. It must be called
+// before the client is used for requests.
+func (c *Client) SetRetryPolicy(policy RetryPolicy) {
+	c.retry = policy
+}
+
+// doRequest performs an HTTP request and decodes the response, retrying
+// idempotent requests on transient failures. The endpoint template
+// identifies the rate limit bucket the request counts against.
+func (c *Client) doRequest(ctx context.Context, method, endpoint, path string, params url.Values, result interface{}) error {
 	reqURL := c.baseURL + path
 	if len(params) > 0 {
 		reqURL += "?" + params.Encode()
 	}
 
+	idempotent := method == http.MethodGet || method == http.MethodHead
+
+	for attempt := 1; ; attempt++ {
+		err := c.doAttempt(ctx, method, endpoint, reqURL, result)
+		if err == nil || !idempotent || attempt >= c.retry.MaxAttempts || !isTransient(err) || ctx.Err() != nil {
+			return err
+		}
+
+		if c.retry.OnRetry != nil {
+			c.retry.OnRetry(endpoint, attempt+1, c.retry.MaxAttempts, err)
+		}
+
+		timer := time.NewTimer(c.retry.backoff(attempt))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// doAttempt performs a single HTTP request and decodes the response
+func (c *Client) doAttempt(ctx context.Context, method, endpoint, reqURL string, result interface{}) error {
+	// Don't spend a request that would certainly be rejected
+	if rl, ok := c.rateLimits.get(endpoint); ok && rl.Exhausted() {
+		retryAfter := secondsUntil(rl.Reset)
+		return &RateLimitError{
+			Endpoint:   endpoint,
+			RetryAfter: retryAfter,
+			Reset:      rl.Reset,
+			Message:    fmt.Sprintf("Rate limit exhausted for %s. Resets in %d seconds", endpoint, retryAfter),
+		}
+	}
+
 	req, err := http.NewRequestWithContext(ctx, method, reqURL, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
@@ -84,22 +237,29 @@ func (c *Client) doRequest(ctx context.Context, method, path string, params url.
 		return fmt.Errorf("failed to read response: %w", err)
 	}
 
+	rl, hasRateLimit := c.rateLimits.update(endpoint, resp.Header)
+
 	// Handle rate limiting
 	if resp.StatusCode == 429 {
-		retryAfter := 60 // default 60 seconds
-		if ra := resp.Header.Get("Retry-After"); ra != "" {
+		reset := time.Now().Add(60 * time.Second) // default 60 seconds
+		if hasRateLimit && rl.Reset.After(time.Now()) {
+			reset = rl.Reset
+		} else if ra := resp.Header.Get("Retry-After"); ra != "" {
 			if v, err := strconv.Atoi(ra); err == nil {
-				retryAfter = v
+				reset = time.Now().Add(time.Duration(v) * time.Second)
 			}
 		}
+		retryAfter := secondsUntil(reset)
 		return &RateLimitError{
+			Endpoint:   endpoint,
 			RetryAfter: retryAfter,
+			Reset:      reset,
 			Message:    fmt.Sprintf("Rate limited. Try again in %d seconds", retryAfter),
 		}
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("API error (status %d): %s", resp.StatusCode, string(body))
+		return decodeAPIError(resp.StatusCode, body)
 	}
 
 	if err := json.Unmarshal(body, result); err != nil {
@@ -111,16 +271,15 @@ func (c *Client) doRequest(ctx context.Context, method, path string, params url.
 
 // GetHomeTimeline fetches the authenticated user's home timeline
 func (c *Client) GetHomeTimeline(ctx context.Context, maxResults int, paginationToken string) (*TimelineResponse, error) {
-	// First get the authenticated user's ID
-	me, err := c.GetMe(ctx)
+	me, err := c.CurrentUser(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
 	params := url.Values{}
-	params.Set("tweet.fields", "created_at,public_metrics,author_id")
+	params.Set("tweet.fields", tweetFields)
 	params.Set("user.fields", "name,username,profile_image_url,verified,public_metrics")
-	params.Set("expansions", "author_id")
+	setExpansions(params)
 	if maxResults > 0 {
 		params.Set("max_results", fmt.Sprintf("%d", maxResults))
 	}
@@ -130,24 +289,80 @@ func (c *Client) GetHomeTimeline(ctx context.Context, maxResults int, pagination
 
 	var result TimelineResponse
 	path := fmt.Sprintf("/users/%s/timelines/reverse_chronological", me.ID)
-	if err := c.doRequest(ctx, "GET", path, params, &result); err != nil {
+	if err := c.doRequest(ctx, "GET", EndpointHomeTimeline, path, params, &result); err != nil {
 		return nil, err
 	}
 
 	return &result, nil
 }
 
+// CurrentUser returns the authenticated user, resolving it once per session
+// and falling back to the user cache before calling /users/me
+func (c *Client) CurrentUser(ctx context.Context) (*User, error) {
+	if c.appOnly {
+		return nil, ErrUserContextRequired
+	}
+	if me := c.Me(); me != nil {
+		return me, nil
+	}
+
+	c.meMu.Lock()
+	cache := c.userCache
+	c.meMu.Unlock()
+
+	if cache != nil {
+		if user, err := cache.LoadUser(); err == nil && user.ID != "" {
+			c.setMe(user)
+			return user, nil
+		}
+	}
+
+	user, err := c.GetMe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.setMe(user)
+
+	if cache != nil {
+		// Best effort; the in-memory copy still saves requests this session
+		_ = cache.SaveUser(user)
+	}
+
+	return user, nil
+}
+
+func (c *Client) setMe(user *User) {
+	c.meMu.Lock()
+	defer c.meMu.Unlock()
+	c.me = user
+}
+
+// Me returns the authenticated user if it has already been resolved
+func (c *Client) Me() *User {
+	c.meMu.Lock()
+	defer c.meMu.Unlock()
+	return c.me
+}
+
 // GetMe returns the authenticated user
 func (c *Client) GetMe(ctx context.Context) (*User, error) {
+	if c.appOnly {
+		return nil, ErrUserContextRequired
+	}
+
 	params := url.Values{}
 	params.Set("user.fields", "name,username,description,profile_image_url,verified,public_metrics")
 
 	var result struct {
-		Data User `json:"data"`
+		Data   User            `json:"data"`
+		Errors []ResourceError `json:"errors,omitempty"`
 	}
-	if err := c.doRequest(ctx, "GET", "/users/me", params, &result); err != nil {
+	if err := c.doRequest(ctx, "GET", EndpointMe, "/users/me", params, &result); err != nil {
 		return nil, err
 	}
+	if result.Data.ID == "" {
+		return nil, missingResource(result.Errors)
+	}
 
 	return &result.Data, nil
 }
@@ -158,12 +373,16 @@ func (c *Client) GetUser(ctx context.Context, username string) (*User, error) {
 	params.Set("user.fields", "name,username,description,profile_image_url,verified,public_metrics")
 
 	var result struct {
-		Data User `json:"data"`
+		Data   User            `json:"data"`
+		Errors []ResourceError `json:"errors,omitempty"`
 	}
 	path := fmt.Sprintf("/users/by/username/%s", username)
-	if err := c.doRequest(ctx, "GET", path, params, &result); err != nil {
+	if err := c.doRequest(ctx, "GET", EndpointUserByName, path, params, &result); err != nil {
 		return nil, err
 	}
+	if result.Data.ID == "" {
+		return nil, missingResource(result.Errors)
+	}
 
 	return &result.Data, nil
 }
@@ -171,9 +390,9 @@ func (c *Client) GetUser(ctx context.Context, username string) (*User, error) {
 // GetUserTweets fetches tweets from a user
 func (c *Client) GetUserTweets(ctx context.Context, userID string, maxResults int, paginationToken string) (*TimelineResponse, error) {
 	params := url.Values{}
-	params.Set("tweet.fields", "created_at,public_metrics,author_id")
+	params.Set("tweet.fields", tweetFields)
 	params.Set("user.fields", "name,username,profile_image_url,verified")
-	params.Set("expansions", "author_id")
+	setExpansions(params)
 	if maxResults > 0 {
 		params.Set("max_results", fmt.Sprintf("%d", maxResults))
 	}
@@ -183,7 +402,7 @@ func (c *Client) GetUserTweets(ctx context.Context, userID string, maxResults in
 
 	var result TimelineResponse
 	path := fmt.Sprintf("/users/%s/tweets", userID)
-	if err := c.doRequest(ctx, "GET", path, params, &result); err != nil {
+	if err := c.doRequest(ctx, "GET", EndpointUserTweets, path, params, &result); err != nil {
 		return nil, err
 	}
 
@@ -194,9 +413,9 @@ func (c *Client) GetUserTweets(ctx context.Context, userID string, maxResults in
 func (c *Client) SearchTweets(ctx context.Context, query string, maxResults int, nextToken string) (*SearchResponse, error) {
 	params := url.Values{}
 	params.Set("query", query)
-	params.Set("tweet.fields", "created_at,public_metrics,author_id")
+	params.Set("tweet.fields", tweetFields)
 	params.Set("user.fields", "name,username,profile_image_url,verified")
-	params.Set("expansions", "author_id")
+	setExpansions(params)
 	if maxResults > 0 {
 		params.Set("max_results", fmt.Sprintf("%d", maxResults))
 	}
@@ -205,33 +424,34 @@ func (c *Client) SearchTweets(ctx context.Context, query string, maxResults int,
 	}
 
 	var result SearchResponse
-	if err := c.doRequest(ctx, "GET", "/tweets/search/recent", params, &result); err != nil {
+	if err := c.doRequest(ctx, "GET", EndpointSearch, "/tweets/search/recent", params, &result); err != nil {
 		return nil, err
 	}
 
 	return &result, nil
 }
 
-// GetTweet fetches a single tweet by ID
-func (c *Client) GetTweet(ctx context.Context, tweetID string) (*Tweet, *User, error) {
+// GetConversation fetches the recent replies in a conversation. The
+// conversation's root tweet is not included; use GetTweet for that.
+func (c *Client) GetConversation(ctx context.Context, conversationID string) (*SearchResponse, error) {
+	return c.SearchTweets(ctx, "conversation_id:"+conversationID, 100, "")
+}
+
+// GetTweet fetches a single tweet by ID along with its expansions
+func (c *Client) GetTweet(ctx context.Context, tweetID string) (*TweetResponse, error) {
 	params := url.Values{}
-	params.Set("tweet.fields", "created_at,public_metrics,author_id")
+	params.Set("tweet.fields", tweetFields)
 	params.Set("user.fields", "name,username,profile_image_url,verified,public_metrics")
-	params.Set("expansions", "author_id")
+	setExpansions(params)
 
-	var result struct {
-		Data     Tweet    `json:"data"`
-		Includes Includes `json:"includes"`
-	}
+	var result TweetResponse
 	path := fmt.Sprintf("/tweets/%s", tweetID)
-	if err := c.doRequest(ctx, "GET", path, params, &result); err != nil {
-		return nil, nil, err
+	if err := c.doRequest(ctx, "GET", EndpointTweet, path, params, &result); err != nil {
+		return nil, err
 	}
-
-	var author *User
-	if len(result.Includes.Users) > 0 {
-		author = &result.Includes.Users[0]
+	if result.Data.ID == "" {
+		return nil, missingResource(result.Errors)
 	}
 
-	return &result.Data, author, nil
+	return &result, nil
 }
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ResourceError is one entry of an "errors" array. On a 200 response it
+// describes a partial failure, e.g. a deleted tweet or a suspended author;
+// on a failed request it details what was wrong with it.
+type ResourceError struct {
+	Title        string              `json:"title,omitempty"`
+	Detail       string              `json:"detail,omitempty"`
+	Type         string              `json:"type,omitempty"`
+	ResourceType string              `json:"resource_type,omitempty"`
+	ResourceID   string              `json:"resource_id,omitempty"`
+	Parameter    string              `json:"parameter,omitempty"`
+	Parameters   map[string][]string `json:"parameters,omitempty"`
+	Value        interface{}         `json:"value,omitempty"`
+	Message      string              `json:"message,omitempty"`
+	Code         int                 `json:"code,omitempty"`
+}
+
+// Summary returns the most descriptive message available
+func (e ResourceError) Summary() string {
+	switch {
+	case e.Detail != "":
+		return e.Detail
+	case e.Message != "":
+		return e.Message
+	default:
+		return e.Title
+	}
+}
+
+// APIError is a failed request, decoded from X's problem-details payload
+type APIError struct {
+	Status int             `json:"status"`
+	Title  string          `json:"title,omitempty"`
+	Detail string          `json:"detail,omitempty"`
+	Type   string          `json:"type,omitempty"`
+	Errors []ResourceError `json:"errors,omitempty"`
+
+	// Body holds the raw response when it isn't a problem-details payload
+	Body string `json:"-"`
+}
+
+func (e *APIError) Error() string {
+	var parts []string
+	if e.Title != "" {
+		parts = append(parts, e.Title)
+	}
+	if e.Detail != "" && e.Detail != e.Title {
+		parts = append(parts, e.Detail)
+	}
+	for _, re := range e.Errors {
+		if s := re.Summary(); s != "" && s != e.Detail {
+			parts = append(parts, s)
+		}
+	}
+	if len(parts) == 0 && e.Body != "" {
+		parts = append(parts, e.Body)
+	}
+	return fmt.Sprintf("API error (status %d): %s", e.Status, strings.Join(parts, ": "))
+}
+
+// NotFound reports whether the requested object doesn't exist or can't be
+// seen, as opposed to the request itself failing
+func (e *APIError) NotFound() bool {
+	return e.Status == http.StatusNotFound || e.Status == http.StatusOK
+}
+
+// decodeAPIError builds an APIError from a non-200 response
+func decodeAPIError(status int, body []byte) *APIError {
+	apiErr := &APIError{}
+	if err := json.Unmarshal(body, apiErr); err != nil || (apiErr.Title == "" && apiErr.Detail == "" && len(apiErr.Errors) == 0) {
+		apiErr = &APIError{Body: strings.TrimSpace(string(body))}
+	}
+	// The HTTP status is authoritative
+	apiErr.Status = status
+	return apiErr
+}
+
+// missingResource turns the errors of a 200 response whose requested
+// object is absent into an APIError
+func missingResource(errs []ResourceError) error {
+	apiErr := &APIError{Status: 200, Errors: errs}
+	if len(errs) > 0 {
+		apiErr.Title = errs[0].Title
+		apiErr.Detail = errs[0].Summary()
+		apiErr.Type = errs[0].Type
+	} else {
+		apiErr.Title = "Not Found"
+	}
+	return apiErr
+}
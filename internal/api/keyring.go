@@ -0,0 +1,193 @@
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keyringService is the service name tokens are stored under
+const keyringService = "xjson"
+
+// ErrSecretNotFound is returned by a SecretService for a missing secret
+var ErrSecretNotFound = errors.New("secret not found")
+
+// SecretService is the part of an OS keyring used to store tokens. Tests
+// can substitute an in-memory one such as apitest.SecretService.
+type SecretService interface {
+	Get(service, user string) (string, error)
+	Set(service, user, secret string) error
+	Delete(service, user string) error
+}
+
+// KeyringBackend stores tokens in a SecretService, one secret per account
+type KeyringBackend struct {
+	service SecretService
+	account string
+}
+
+// NewKeyringBackend creates a keyring backend for an account
+func NewKeyringBackend(service SecretService, account string) *KeyringBackend {
+	if account == "" {
+		account = "default"
+	}
+	return &KeyringBackend{service: service, account: account}
+}
+
+// Read implements TokenBackend
+func (b *KeyringBackend) Read() ([]byte, error) {
+	secret, err := b.service.Get(keyringService, b.account)
+	if errors.Is(err, ErrSecretNotFound) {
+		return nil, fmt.Errorf("%w in %s", ErrNoToken, b)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(secret)
+}
+
+// Write implements TokenBackend. The token is base64 encoded, since some
+// keyring tools mangle secrets containing newlines.
+func (b *KeyringBackend) Write(data []byte) error {
+	return b.service.Set(keyringService, b.account, base64.StdEncoding.EncodeToString(data))
+}
+
+// Delete implements TokenBackend
+func (b *KeyringBackend) Delete() error {
+	if err := b.service.Delete(keyringService, b.account); err != nil && !errors.Is(err, ErrSecretNotFound) {
+		return err
+	}
+	return nil
+}
+
+// Exists implements TokenBackend
+func (b *KeyringBackend) Exists() bool {
+	_, err := b.Read()
+	return err == nil
+}
+
+func (b *KeyringBackend) String() string {
+	return fmt.Sprintf("keyring %s/%s", keyringService, b.account)
+}
+
+// NewSystemSecretService returns the keyring of the OS: the freedesktop
+// Secret Service through secret-tool, or the macOS login keychain through
+// security
+func NewSystemSecretService() (SecretService, error) {
+	switch runtime.GOOS {
+	case "darwin":
+		return keychain{}, nil
+	case "linux", "freebsd", "openbsd", "netbsd":
+		if _, err := exec.LookPath("secret-tool"); err != nil {
+			return nil, fmt.Errorf("secret-tool not found; install libsecret-tools to use the keyring")
+		}
+		return secretTool{}, nil
+	}
+	return nil, fmt.Errorf("no keyring support on %s", runtime.GOOS)
+}
+
+// secretTool talks to the Secret Service with libsecret's secret-tool
+type secretTool struct{}
+
+func (secretTool) Get(service, user string) (string, error) {
+	out, err := runKeyringTool(nil, "secret-tool", "lookup", "service", service, "account", user)
+	if err != nil {
+		// lookup exits 1 without output when there is no such secret
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(bytes.TrimSpace(exitErr.Stderr)) == 0 {
+			return "", ErrSecretNotFound
+		}
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (secretTool) Set(service, user, secret string) error {
+	_, err := runKeyringTool(strings.NewReader(secret), "secret-tool", "store",
+		"--label=xjson token ("+user+")", "service", service, "account", user)
+	return err
+}
+
+func (secretTool) Delete(service, user string) error {
+	_, err := runKeyringTool(nil, "secret-tool", "clear", "service", service, "account", user)
+	return err
+}
+
+// keychain talks to the macOS keychain with the security tool
+type keychain struct{}
+
+// keychainNotFound is the exit status of security for a missing item
+const keychainNotFound = 44
+
+func (keychain) Get(service, user string) (string, error) {
+	out, err := runKeyringTool(nil, "security", "find-generic-password", "-s", service, "-a", user, "-w")
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == keychainNotFound {
+			return "", ErrSecretNotFound
+		}
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
+
+func (keychain) Set(service, user, secret string) error {
+	// add-generic-password only takes the secret as an argument, so the
+	// command is fed to security's interactive mode to keep the secret out
+	// of the process list
+	line := fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+		keychainQuote(service), keychainQuote(user), keychainQuote(secret))
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(line)
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+
+	// Interactive mode can exit 0 after a failed command, so anything on
+	// stderr counts as a failure
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return fmt.Errorf("security add-generic-password: %s", msg)
+	}
+	if err != nil {
+		return fmt.Errorf("security add-generic-password: %w", err)
+	}
+	return nil
+}
+
+func (keychain) Delete(service, user string) error {
+	_, err := runKeyringTool(nil, "security", "delete-generic-password", "-s", service, "-a", user)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == keychainNotFound {
+		return ErrSecretNotFound
+	}
+	return err
+}
+
+// keychainQuote quotes an argument for a security -i command line
+func keychainQuote(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return `"` + strings.ReplaceAll(s, `"`, `\"`) + `"`
+}
+
+// runKeyringTool runs a keyring command and returns its output. On failure
+// the error keeps the *exec.ExitError with stderr.
+func runKeyringTool(stdin *strings.Reader, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%s %s: %s: %w", name, args[0], strings.TrimSpace(string(exitErr.Stderr)), err)
+		}
+		return "", fmt.Errorf("%s %s: %w", name, args[0], err)
+	}
+	return string(out), nil
+}
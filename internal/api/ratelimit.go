@@ -0,0 +1,92 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Endpoint templates, used as keys for per-endpoint rate limits
+const (
+	EndpointMe           = "/users/me"
+	EndpointHomeTimeline = "/users/:id/timelines/reverse_chronological"
+	EndpointUserByName   = "/users/by/username/:username"
+	EndpointUserTweets   = "/users/:id/tweets"
+	EndpointSearch       = "/tweets/search/recent"
+	EndpointTweet        = "/tweets/:id"
+)
+
+// RateLimit is the request budget for one endpoint, as reported by the
+// x-rate-limit-* response headers
+type RateLimit struct {
+	Limit     int
+	Remaining int
+	Reset     time.Time
+}
+
+// Exhausted reports whether a request sent now would certainly be rejected
+func (rl RateLimit) Exhausted() bool {
+	return rl.Remaining <= 0 && time.Now().Before(rl.Reset)
+}
+
+// rateLimiter tracks rate limits per endpoint
+type rateLimiter struct {
+	mu     sync.Mutex
+	limits map[string]RateLimit
+}
+
+func newRateLimiter() *rateLimiter {
+	return &rateLimiter{limits: make(map[string]RateLimit)}
+}
+
+// update records the rate limit headers of a response, if present
+func (r *rateLimiter) update(endpoint string, h http.Header) (RateLimit, bool) {
+	rl, ok := parseRateLimit(h)
+	if !ok {
+		return RateLimit{}, false
+	}
+
+	r.mu.Lock()
+	r.limits[endpoint] = rl
+	r.mu.Unlock()
+
+	return rl, true
+}
+
+// get returns the last known rate limit for an endpoint
+func (r *rateLimiter) get(endpoint string) (RateLimit, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	rl, ok := r.limits[endpoint]
+	return rl, ok
+}
+
+// secondsUntil returns the whole seconds left until t, rounded up
+func secondsUntil(t time.Time) int {
+	return int(math.Ceil(time.Until(t).Seconds()))
+}
+
+// parseRateLimit reads the x-rate-limit-* headers
+func parseRateLimit(h http.Header) (RateLimit, bool) {
+	limit, err := strconv.Atoi(h.Get("x-rate-limit-limit"))
+	if err != nil {
+		return RateLimit{}, false
+	}
+	remaining, err := strconv.Atoi(h.Get("x-rate-limit-remaining"))
+	if err != nil {
+		return RateLimit{}, false
+	}
+	reset, err := strconv.ParseInt(h.Get("x-rate-limit-reset"), 10, 64)
+	if err != nil {
+		return RateLimit{}, false
+	}
+
+	return RateLimit{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     time.Unix(reset, 0),
+	}, true
+}
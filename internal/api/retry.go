@@ -0,0 +1,71 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"syscall"
+	"time"
+)
+
+// RetryPolicy controls how idempotent requests are retried on transient
+// failures: connection resets, timeouts and 500/502/503/504 responses
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first; 1 disables retries
+	BaseDelay   time.Duration // delay before the first retry, doubled for each one after
+	MaxDelay    time.Duration // upper bound for a single delay
+
+	// OnRetry, if set, is called before each retry with the attempt about
+	// to be made, the total allowed and the error that caused it
+	OnRetry func(endpoint string, attempt, maxAttempts int, err error)
+}
+
+// DefaultRetryPolicy returns the retry policy used by new clients
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   500 * time.Millisecond,
+		MaxDelay:    8 * time.Second,
+	}
+}
+
+// backoff returns the delay before the given retry (1 for the first) using
+// exponential backoff with full jitter
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	if p.BaseDelay <= 0 {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < retry && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	return time.Duration(rand.Int63n(int64(delay) + 1))
+}
+
+// isTransient reports whether a failed request is worth retrying
+func isTransient(err error) bool {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.Status {
+		case 500, 502, 503, 504:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	return errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, io.EOF)
+}
@@ -0,0 +1,274 @@
+package api
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"sync"
+)
+
+// ErrNoToken is returned by a TokenBackend that has nothing stored
+var ErrNoToken = errors.New("no stored token")
+
+// TokenBackend stores the serialized token of one account. Read returns an
+// error wrapping ErrNoToken when nothing is stored.
+type TokenBackend interface {
+	Read() ([]byte, error)
+	Write(data []byte) error
+	Delete() error
+	Exists() bool
+	String() string
+}
+
+// FileBackend stores tokens as plain JSON in a file readable only by the
+// user. It is the default backend.
+type FileBackend struct {
+	path string
+}
+
+// NewFileBackend creates a file backend
+func NewFileBackend(path string) *FileBackend {
+	return &FileBackend{path: path}
+}
+
+// Read implements TokenBackend
+func (b *FileBackend) Read() ([]byte, error) {
+	return readTokenFile(b.path)
+}
+
+// Write implements TokenBackend
+func (b *FileBackend) Write(data []byte) error {
+	return os.WriteFile(b.path, data, 0600)
+}
+
+// Delete implements TokenBackend
+func (b *FileBackend) Delete() error {
+	return removeTokenFile(b.path)
+}
+
+// Exists implements TokenBackend
+func (b *FileBackend) Exists() bool {
+	_, err := os.Stat(b.path)
+	return err == nil
+}
+
+func (b *FileBackend) String() string {
+	return "file " + b.path
+}
+
+// Key derivation functions of the encrypted backend
+const (
+	kdfPassphrase    = "pbkdf2-sha256"
+	kdfKeyFile       = "hkdf-sha256"
+	pbkdf2Iterations = 600000
+)
+
+// encryptedToken is the on-disk format of the encrypted backend. The KDF
+// name is authenticated along with the ciphertext.
+type encryptedToken struct {
+	Version    int    `json:"version"`
+	KDF        string `json:"kdf"`
+	Iterations int    `json:"iterations,omitempty"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// EncryptedFileBackend stores tokens in a file encrypted with AES-256-GCM.
+// The key is derived from a passphrase with PBKDF2, or from the contents of
+// a key file with HKDF, using a random salt kept in the file.
+type EncryptedFileBackend struct {
+	path   string
+	secret []byte
+	kdf    string
+
+	mu   sync.Mutex
+	salt []byte // salt of the cached key
+	key  []byte
+}
+
+// NewEncryptedFileBackend creates an encrypted backend keyed by a passphrase
+func NewEncryptedFileBackend(path string, passphrase []byte) *EncryptedFileBackend {
+	return &EncryptedFileBackend{path: path, secret: passphrase, kdf: kdfPassphrase}
+}
+
+// NewEncryptedFileBackendWithKeyFile creates an encrypted backend keyed by
+// the contents of a key file, such as 32 bytes from /dev/urandom
+func NewEncryptedFileBackendWithKeyFile(path, keyFile string) (*EncryptedFileBackend, error) {
+	secret, err := os.ReadFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	secret = bytes.TrimSpace(secret)
+	if len(secret) < 16 {
+		return nil, fmt.Errorf("key file %s is too short (need at least 16 bytes)", keyFile)
+	}
+	return &EncryptedFileBackend{path: path, secret: secret, kdf: kdfKeyFile}, nil
+}
+
+// Read implements TokenBackend
+func (b *EncryptedFileBackend) Read() ([]byte, error) {
+	data, err := readTokenFile(b.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var env encryptedToken
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, fmt.Errorf("failed to parse encrypted token file: %w", err)
+	}
+	if env.Version != 1 {
+		return nil, fmt.Errorf("unsupported encrypted token file version %d", env.Version)
+	}
+	if env.KDF != b.kdf {
+		return nil, fmt.Errorf("token file %s was encrypted with a %s, not a %s", b.path, kdfSource(env.KDF), kdfSource(b.kdf))
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	gcm, err := b.cipher(env.Salt, env.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, env.Nonce, env.Ciphertext, []byte(env.KDF))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt token file %s: wrong %s", b.path, kdfSource(b.kdf))
+	}
+	return plain, nil
+}
+
+// Write implements TokenBackend
+func (b *EncryptedFileBackend) Write(data []byte) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// Reuse the salt of the cached key so refreshes don't rerun the KDF
+	salt := b.salt
+	if salt == nil {
+		salt = make([]byte, 16)
+		if _, err := rand.Read(salt); err != nil {
+			return err
+		}
+	}
+
+	gcm, err := b.cipher(salt, pbkdf2Iterations)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+
+	env := encryptedToken{
+		Version:    1,
+		KDF:        b.kdf,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: gcm.Seal(nil, nonce, data, []byte(b.kdf)),
+	}
+	if b.kdf == kdfPassphrase {
+		env.Iterations = pbkdf2Iterations
+	}
+
+	out, err := json.MarshalIndent(env, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(b.path, out, 0600)
+}
+
+// Delete implements TokenBackend
+func (b *EncryptedFileBackend) Delete() error {
+	return removeTokenFile(b.path)
+}
+
+// Exists implements TokenBackend
+func (b *EncryptedFileBackend) Exists() bool {
+	_, err := os.Stat(b.path)
+	return err == nil
+}
+
+func (b *EncryptedFileBackend) String() string {
+	return "encrypted file " + b.path
+}
+
+// cipher returns the AES-GCM cipher for a salt, deriving the key unless it
+// is cached. b.mu must be held.
+func (b *EncryptedFileBackend) cipher(salt []byte, iterations int) (cipher.AEAD, error) {
+	if b.key == nil || !bytes.Equal(salt, b.salt) {
+		var key []byte
+		var err error
+		switch b.kdf {
+		case kdfPassphrase:
+			key, err = pbkdf2.Key(sha256.New, string(b.secret), salt, iterations, 32)
+		default:
+			key, err = hkdf.Key(sha256.New, b.secret, salt, "xjson token", 32)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to derive token key: %w", err)
+		}
+		b.salt, b.key = salt, key
+	}
+
+	block, err := aes.NewCipher(b.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// kdfSource describes what a KDF derives its key from
+func kdfSource(kdf string) string {
+	if kdf == kdfKeyFile {
+		return "key file"
+	}
+	return "passphrase"
+}
+
+// MigrateTokens moves the stored token from one backend to another. The
+// source is only removed once the copy reads back intact.
+func MigrateTokens(from, to TokenBackend) error {
+	data, err := from.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read token from %s: %w", from, err)
+	}
+	if err := to.Write(data); err != nil {
+		return fmt.Errorf("failed to write token to %s: %w", to, err)
+	}
+
+	check, err := to.Read()
+	if err != nil || !bytes.Equal(check, data) {
+		return fmt.Errorf("token written to %s did not read back intact; %s was left in place", to, from)
+	}
+
+	if err := from.Delete(); err != nil {
+		return fmt.Errorf("token copied to %s, but failed to remove it from %s: %w", to, from, err)
+	}
+	return nil
+}
+
+func readTokenFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w in %s", ErrNoToken, path)
+	}
+	return data, err
+}
+
+func removeTokenFile(path string) error {
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
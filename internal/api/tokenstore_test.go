@@ -0,0 +1,205 @@
+package api_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kenan/xjson/internal/api"
+	"github.com/kenan/xjson/internal/api/apitest"
+)
+
+var testToken = []byte(`{"access_token":"a","refresh_token":"r","token_type":"bearer"}`)
+
+// writeKeyFile creates a key file with the given contents
+func writeKeyFile(t *testing.T, contents string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "token.key")
+	if err := os.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestEncryptedFileBackendRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.enc")
+
+	passphrase := api.NewEncryptedFileBackend(path, []byte("correct horse"))
+	if err := passphrase.Write(testToken); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(raw, []byte("refresh_token")) {
+		t.Fatalf("token file holds the token in the clear: %s", raw)
+	}
+
+	// A fresh backend has to derive the key from the file's salt
+	got, err := api.NewEncryptedFileBackend(path, []byte("correct horse")).Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if !bytes.Equal(got, testToken) {
+		t.Fatalf("Read = %s, want %s", got, testToken)
+	}
+
+	keyFile := writeKeyFile(t, "0123456789abcdef0123456789abcdef\n")
+	keyPath := filepath.Join(t.TempDir(), "token.enc")
+	keyed, err := api.NewEncryptedFileBackendWithKeyFile(keyPath, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keyed.Write(testToken); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	reopened, err := api.NewEncryptedFileBackendWithKeyFile(keyPath, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := reopened.Read(); err != nil || !bytes.Equal(got, testToken) {
+		t.Fatalf("Read = %s, %v, want %s", got, err, testToken)
+	}
+}
+
+func TestEncryptedFileBackendMissing(t *testing.T) {
+	b := api.NewEncryptedFileBackend(filepath.Join(t.TempDir(), "token.enc"), []byte("pw"))
+	if b.Exists() {
+		t.Fatal("Exists = true for a missing file")
+	}
+	if _, err := b.Read(); !errors.Is(err, api.ErrNoToken) {
+		t.Fatalf("Read error = %v, want ErrNoToken", err)
+	}
+}
+
+func TestEncryptedFileBackendWrongSecret(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "pass.enc")
+	if err := api.NewEncryptedFileBackend(path, []byte("right")).Write(testToken); err != nil {
+		t.Fatal(err)
+	}
+	_, err := api.NewEncryptedFileBackend(path, []byte("wrong")).Read()
+	if err == nil || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Fatalf("Read error = %v, want wrong passphrase", err)
+	}
+
+	keyPath := filepath.Join(dir, "key.enc")
+	right, err := api.NewEncryptedFileBackendWithKeyFile(keyPath, writeKeyFile(t, "0123456789abcdef-right"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := right.Write(testToken); err != nil {
+		t.Fatal(err)
+	}
+	wrong, err := api.NewEncryptedFileBackendWithKeyFile(keyPath, writeKeyFile(t, "0123456789abcdef-wrong"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := wrong.Read(); err == nil || !strings.Contains(err.Error(), "wrong key file") {
+		t.Fatalf("Read error = %v, want wrong key file", err)
+	}
+}
+
+func TestEncryptedFileBackendShortKeyFile(t *testing.T) {
+	_, err := api.NewEncryptedFileBackendWithKeyFile(filepath.Join(t.TempDir(), "token.enc"), writeKeyFile(t, "short\n"))
+	if err == nil {
+		t.Fatal("short key file was accepted")
+	}
+}
+
+func TestEncryptedFileBackendKDFMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token.enc")
+	keyed, err := api.NewEncryptedFileBackendWithKeyFile(path, writeKeyFile(t, "0123456789abcdef0123456789abcdef"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keyed.Write(testToken); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = api.NewEncryptedFileBackend(path, []byte("0123456789abcdef0123456789abcdef")).Read()
+	if err == nil || !strings.Contains(err.Error(), "was encrypted with a key file, not a passphrase") {
+		t.Fatalf("Read error = %v, want KDF mismatch", err)
+	}
+}
+
+func TestMigrateTokens(t *testing.T) {
+	dir := t.TempDir()
+	file := api.NewFileBackend(filepath.Join(dir, "token.json"))
+	encrypted := api.NewEncryptedFileBackend(filepath.Join(dir, "token.enc"), []byte("pw"))
+	keyring := api.NewKeyringBackend(apitest.NewSecretService(), "work")
+
+	if err := file.Write(testToken); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := api.MigrateTokens(file, encrypted); err != nil {
+		t.Fatalf("file to encrypted: %v", err)
+	}
+	if file.Exists() {
+		t.Fatal("plain token file was left behind")
+	}
+
+	if err := api.MigrateTokens(encrypted, keyring); err != nil {
+		t.Fatalf("encrypted to keyring: %v", err)
+	}
+	if encrypted.Exists() {
+		t.Fatal("encrypted token file was left behind")
+	}
+
+	got, err := keyring.Read()
+	if err != nil {
+		t.Fatalf("keyring Read: %v", err)
+	}
+	if !bytes.Equal(got, testToken) {
+		t.Fatalf("keyring Read = %s, want %s", got, testToken)
+	}
+}
+
+func TestMigrateTokensNothingStored(t *testing.T) {
+	from := api.NewKeyringBackend(apitest.NewSecretService(), "")
+	to := api.NewFileBackend(filepath.Join(t.TempDir(), "token.json"))
+
+	err := api.MigrateTokens(from, to)
+	if !errors.Is(err, api.ErrNoToken) {
+		t.Fatalf("MigrateTokens error = %v, want ErrNoToken", err)
+	}
+	if to.Exists() {
+		t.Fatal("destination was written")
+	}
+}
+
+// garbledBackend stores tokens but reads back something else
+type garbledBackend struct {
+	api.TokenBackend
+}
+
+func (b garbledBackend) Read() ([]byte, error) {
+	data, err := b.TokenBackend.Read()
+	return bytes.ToUpper(data), err
+}
+
+func TestMigrateTokensKeepsSourceOnBadReadBack(t *testing.T) {
+	from := api.NewFileBackend(filepath.Join(t.TempDir(), "token.json"))
+	if err := from.Write(testToken); err != nil {
+		t.Fatal(err)
+	}
+	to := garbledBackend{api.NewKeyringBackend(apitest.NewSecretService(), "work")}
+
+	if err := api.MigrateTokens(from, to); err == nil {
+		t.Fatal("MigrateTokens succeeded with a garbled copy")
+	}
+	got, err := from.Read()
+	if err != nil {
+		t.Fatalf("source was removed: %v", err)
+	}
+	if !bytes.Equal(got, testToken) {
+		t.Fatalf("source = %s, want %s", got, testToken)
+	}
+}
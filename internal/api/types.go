@@ -4,11 +4,130 @@ import "time"
 
 // Tweet represents a tweet from the X API
 type Tweet struct {
-	ID        string    `json:"id"`
-	Text      string    `json:"text"`
-	AuthorID  string    `json:"author_id"`
-	CreatedAt time.Time `json:"created_at"`
-	Metrics   *Metrics  `json:"public_metrics,omitempty"`
+	ID               string            `json:"id"`
+	Text             string            `json:"text"`
+	AuthorID         string            `json:"author_id"`
+	CreatedAt        time.Time         `json:"created_at"`
+	Metrics          *Metrics          `json:"public_metrics,omitempty"`
+	ConversationID   string            `json:"conversation_id,omitempty"`
+	InReplyToUserID  string            `json:"in_reply_to_user_id,omitempty"`
+	ReferencedTweets []ReferencedTweet `json:"referenced_tweets,omitempty"`
+	Attachments      *Attachments      `json:"attachments,omitempty"`
+	Entities         *Entities         `json:"entities,omitempty"`
+	NoteTweet        *NoteTweet        `json:"note_tweet,omitempty"`
+	EditHistory      []string          `json:"edit_history_tweet_ids,omitempty"`
+	EditControls     *EditControls     `json:"edit_controls,omitempty"`
+}
+
+// NoteTweet holds the full text of a post over 280 characters, whose Text
+// is cut off
+type NoteTweet struct {
+	Text     string    `json:"text"`
+	Entities *Entities `json:"entities,omitempty"`
+}
+
+// EditControls says whether and until when a tweet can still be edited
+type EditControls struct {
+	EditsRemaining int       `json:"edits_remaining"`
+	IsEditEligible bool      `json:"is_edit_eligible"`
+	EditableUntil  time.Time `json:"editable_until,omitzero"`
+}
+
+// FullText returns the text of a tweet, including the part of a long post
+// that Text cuts off
+func (t *Tweet) FullText() string {
+	if t.NoteTweet != nil && t.NoteTweet.Text != "" {
+		return t.NoteTweet.Text
+	}
+	return t.Text
+}
+
+// FullEntities returns the entities matching FullText
+func (t *Tweet) FullEntities() *Entities {
+	if t.NoteTweet != nil && t.NoteTweet.Text != "" {
+		return t.NoteTweet.Entities
+	}
+	return t.Entities
+}
+
+// Edited reports whether a tweet has more than one version. EditHistory
+// lists the IDs of every version, oldest first.
+func (t *Tweet) Edited() bool {
+	return len(t.EditHistory) > 1
+}
+
+// Entities are the parts of a tweet's text that X parsed out. Start and End
+// are offsets in code points, not bytes.
+type Entities struct {
+	URLs     []URLEntity     `json:"urls,omitempty"`
+	Mentions []MentionEntity `json:"mentions,omitempty"`
+	Hashtags []TagEntity     `json:"hashtags,omitempty"`
+	Cashtags []TagEntity     `json:"cashtags,omitempty"`
+}
+
+// URLEntity is a t.co link and where it leads. Links to attached media
+// carry the media key.
+type URLEntity struct {
+	Start       int    `json:"start"`
+	End         int    `json:"end"`
+	URL         string `json:"url"`
+	ExpandedURL string `json:"expanded_url,omitempty"`
+	DisplayURL  string `json:"display_url,omitempty"`
+	UnwoundURL  string `json:"unwound_url,omitempty"`
+	Title       string `json:"title,omitempty"`
+	MediaKey    string `json:"media_key,omitempty"`
+}
+
+// MentionEntity is an @mention. ID is only set for users that exist.
+type MentionEntity struct {
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+	Username string `json:"username"`
+	ID       string `json:"id,omitempty"`
+}
+
+// TagEntity is a #hashtag or $cashtag, without the prefix
+type TagEntity struct {
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+	Tag   string `json:"tag"`
+}
+
+// Attachments holds the keys of the media and polls attached to a tweet,
+// which are expanded in Includes
+type Attachments struct {
+	MediaKeys []string `json:"media_keys,omitempty"`
+	PollIDs   []string `json:"poll_ids,omitempty"`
+}
+
+// ReferencedTweet links a tweet to the tweet it replies to, quotes or retweets
+type ReferencedTweet struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+}
+
+// RepliedToID returns the ID of the tweet this one replies to, if any
+func (t *Tweet) RepliedToID() string {
+	return t.referencedID("replied_to")
+}
+
+// QuotedID returns the ID of the tweet this one quotes, if any
+func (t *Tweet) QuotedID() string {
+	return t.referencedID("quoted")
+}
+
+// RetweetedID returns the ID of the tweet this one is a retweet of, if any
+func (t *Tweet) RetweetedID() string {
+	return t.referencedID("retweeted")
+}
+
+func (t *Tweet) referencedID(refType string) string {
+	for _, ref := range t.ReferencedTweets {
+		if ref.Type == refType {
+			return ref.ID
+		}
+	}
+	return ""
 }
 
 // Metrics represents tweet engagement metrics
@@ -38,11 +157,51 @@ type TimelineResponse struct {
 	Data     []Tweet         `json:"data"`
 	Includes *Includes       `json:"includes,omitempty"`
 	Meta     *ResponseMeta   `json:"meta,omitempty"`
+	Errors   []ResourceError `json:"errors,omitempty"`
 }
 
 // Includes contains expanded objects
 type Includes struct {
-	Users []User `json:"users,omitempty"`
+	Users  []User  `json:"users,omitempty"`
+	Tweets []Tweet `json:"tweets,omitempty"`
+	Media  []Media `json:"media,omitempty"`
+	Polls  []Poll  `json:"polls,omitempty"`
+}
+
+// Media is an attached photo, video or animated GIF
+type Media struct {
+	MediaKey        string         `json:"media_key"`
+	Type            string         `json:"type"` // photo, video or animated_gif
+	URL             string         `json:"url,omitempty"`
+	PreviewImageURL string         `json:"preview_image_url,omitempty"`
+	Width           int            `json:"width,omitempty"`
+	Height          int            `json:"height,omitempty"`
+	DurationMs      int            `json:"duration_ms,omitempty"`
+	AltText         string         `json:"alt_text,omitempty"`
+	Variants        []MediaVariant `json:"variants,omitempty"`
+}
+
+// MediaVariant is one encoding of a video or GIF
+type MediaVariant struct {
+	BitRate     int    `json:"bit_rate,omitempty"`
+	ContentType string `json:"content_type"`
+	URL         string `json:"url"`
+}
+
+// Poll is an attached poll
+type Poll struct {
+	ID              string       `json:"id"`
+	Options         []PollOption `json:"options"`
+	DurationMinutes int          `json:"duration_minutes,omitempty"`
+	EndDatetime     time.Time    `json:"end_datetime,omitzero"`
+	VotingStatus    string       `json:"voting_status,omitempty"` // open or closed
+}
+
+// PollOption is a poll choice and its vote count
+type PollOption struct {
+	Position int    `json:"position"`
+	Label    string `json:"label"`
+	Votes    int    `json:"votes"`
 }
 
 // ResponseMeta contains pagination info
@@ -52,9 +211,30 @@ type ResponseMeta struct {
 	PreviousToken string `json:"previous_token,omitempty"`
 }
 
+// TweetResponse represents a single tweet lookup
+type TweetResponse struct {
+	Data     Tweet           `json:"data"`
+	Includes *Includes       `json:"includes,omitempty"`
+	Errors   []ResourceError `json:"errors,omitempty"`
+}
+
+// Author returns the tweet's author from the includes, if present
+func (r *TweetResponse) Author() *User {
+	if r.Includes == nil {
+		return nil
+	}
+	for i := range r.Includes.Users {
+		if r.Includes.Users[i].ID == r.Data.AuthorID {
+			return &r.Includes.Users[i]
+		}
+	}
+	return nil
+}
+
 // SearchResponse represents search results
 type SearchResponse struct {
-	Data     []Tweet       `json:"data"`
-	Includes *Includes     `json:"includes,omitempty"`
-	Meta     *ResponseMeta `json:"meta,omitempty"`
+	Data     []Tweet         `json:"data"`
+	Includes *Includes       `json:"includes,omitempty"`
+	Meta     *ResponseMeta   `json:"meta,omitempty"`
+	Errors   []ResourceError `json:"errors,omitempty"`
 }
@@ -0,0 +1,128 @@
+package transform
+
+import "strings"
+
+// diffContext is how many unchanged words are kept on each side of a change
+const diffContext = 6
+
+// maxDiffCells bounds the LCS table; larger changes are shown as a
+// replacement of the whole differing middle
+const maxDiffCells = 1 << 20
+
+// diffOp is a run of words that were kept, removed or added
+type diffOp struct {
+	kind  byte // ' ', '-' or '+'
+	words []string
+}
+
+// wordDiff compares two texts word by word. The diff comes back as lines
+// prefixed "  ", "- " or "+ " like a unified diff, with long unchanged runs
+// shortened to the words around the changes.
+func wordDiff(before, after string) (lines []string, added, removed int) {
+	ops := diffWords(strings.Fields(before), strings.Fields(after))
+	for i, op := range ops {
+		words := op.words
+		switch op.kind {
+		case '-':
+			removed += len(words)
+		case '+':
+			added += len(words)
+		default:
+			words = elide(words, i > 0, i < len(ops)-1)
+		}
+		lines = append(lines, string(op.kind)+" "+strings.Join(words, " "))
+	}
+	return lines, added, removed
+}
+
+// elide shortens an unchanged run to the words next to its neighbouring
+// changes, unless only a few words would be left out
+func elide(words []string, changeBefore, changeAfter bool) []string {
+	var head, tail []string
+	if changeBefore {
+		head = words[:min(diffContext, len(words))]
+	}
+	if changeAfter {
+		tail = words[max(len(words)-diffContext, 0):]
+	}
+	if len(words)-len(head)-len(tail) <= diffContext {
+		return words
+	}
+
+	out := append([]string{}, head...)
+	out = append(out, "…")
+	return append(out, tail...)
+}
+
+// diffWords returns the edit script turning a into b
+func diffWords(a, b []string) []diffOp {
+	// Changes are usually small, so only the differing middle goes
+	// through the LCS
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []diffOp
+	push := func(kind byte, word string) {
+		if n := len(ops); n > 0 && ops[n-1].kind == kind {
+			ops[n-1].words = append(ops[n-1].words, word)
+			return
+		}
+		ops = append(ops, diffOp{kind: kind, words: []string{word}})
+	}
+
+	for _, w := range a[:prefix] {
+		push(' ', w)
+	}
+
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	if (len(midA)+1)*(len(midB)+1) > maxDiffCells {
+		for _, w := range midA {
+			push('-', w)
+		}
+		for _, w := range midB {
+			push('+', w)
+		}
+	} else {
+		// lcs[i][j] is the length of the LCS of midA[i:] and midB[j:]
+		lcs := make([][]int, len(midA)+1)
+		for i := range lcs {
+			lcs[i] = make([]int, len(midB)+1)
+		}
+		for i := len(midA) - 1; i >= 0; i-- {
+			for j := len(midB) - 1; j >= 0; j-- {
+				if midA[i] == midB[j] {
+					lcs[i][j] = lcs[i+1][j+1] + 1
+				} else {
+					lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+				}
+			}
+		}
+
+		i, j := 0, 0
+		for i < len(midA) || j < len(midB) {
+			switch {
+			case i < len(midA) && j < len(midB) && midA[i] == midB[j]:
+				push(' ', midA[i])
+				i++
+				j++
+			case i < len(midA) && (j == len(midB) || lcs[i+1][j] >= lcs[i][j+1]):
+				push('-', midA[i])
+				i++
+			default:
+				push('+', midB[j])
+				j++
+			}
+		}
+	}
+
+	for _, w := range a[len(a)-suffix:] {
+		push(' ', w)
+	}
+	return ops
+}
@@ -0,0 +1,120 @@
+package transform
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// numbered returns count words prefix1, prefix2, ...
+func numbered(prefix string, count int) string {
+	words := make([]string, count)
+	for i := range words {
+		words[i] = fmt.Sprintf("%s%d", prefix, i+1)
+	}
+	return strings.Join(words, " ")
+}
+
+func TestWordDiff(t *testing.T) {
+	tests := []struct {
+		name           string
+		before, after  string
+		want           []string
+		added, removed int
+	}{
+		{
+			name: "empty",
+		},
+		{
+			name:  "added to empty",
+			after: "hello world",
+			want:  []string{"+ hello world"},
+			added: 2,
+		},
+		{
+			name:    "emptied",
+			before:  "hello world",
+			want:    []string{"- hello world"},
+			removed: 2,
+		},
+		{
+			name:   "identical",
+			before: "the quick brown fox",
+			after:  "the  quick brown\nfox",
+			want:   []string{"  the quick brown fox"},
+		},
+		{
+			name:   "identical long text",
+			before: numbered("w", 20),
+			after:  numbered("w", 20),
+			want:   []string{"  …"},
+		},
+		{
+			name:    "single word",
+			before:  "the quick brown fox",
+			after:   "the quick red fox",
+			want:    []string{"  the quick", "- brown", "+ red", "  fox"},
+			added:   1,
+			removed: 1,
+		},
+		{
+			name:   "insertion",
+			before: "ship it",
+			after:  "ship it today",
+			want:   []string{"  ship it", "+ today"},
+			added:  1,
+		},
+		{
+			name:    "elided around a change",
+			before:  numbered("a", 20) + " old " + numbered("b", 20),
+			after:   numbered("a", 20) + " new " + numbered("b", 20),
+			want:    []string{"  … a15 a16 a17 a18 a19 a20", "- old", "+ new", "  b1 b2 b3 b4 b5 b6 …"},
+			added:   1,
+			removed: 1,
+		},
+		{
+			name:    "elided between changes",
+			before:  "x " + numbered("a", 20) + " y",
+			after:   "X " + numbered("a", 20) + " Y",
+			want:    []string{"- x", "+ X", "  a1 a2 a3 a4 a5 a6 … a15 a16 a17 a18 a19 a20", "- y", "+ Y"},
+			added:   2,
+			removed: 2,
+		},
+		{
+			name:    "short run kept between changes",
+			before:  "x " + numbered("a", 18) + " y",
+			after:   "X " + numbered("a", 18) + " Y",
+			want:    []string{"- x", "+ X", "  " + numbered("a", 18), "- y", "+ Y"},
+			added:   2,
+			removed: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, added, removed := wordDiff(tt.before, tt.after)
+			if !slices.Equal(lines, tt.want) {
+				t.Errorf("lines = %q, want %q", lines, tt.want)
+			}
+			if added != tt.added || removed != tt.removed {
+				t.Errorf("added, removed = %d, %d, want %d, %d", added, removed, tt.added, tt.removed)
+			}
+		})
+	}
+}
+
+func TestWordDiffLargeChange(t *testing.T) {
+	// Too many cells for the LCS table, so the middle is replaced wholesale
+	before := "start " + numbered("a", 1100) + " end"
+	after := "start " + numbered("b", 1100) + " end"
+
+	lines, added, removed := wordDiff(before, after)
+	if added != 1100 || removed != 1100 {
+		t.Fatalf("added, removed = %d, %d, want 1100, 1100", added, removed)
+	}
+	want := []string{"  start", "- " + numbered("a", 1100), "+ " + numbered("b", 1100), "  end"}
+	if !slices.Equal(lines, want) {
+		t.Fatalf("got %d lines starting %q, want the middle replaced", len(lines), lines[0])
+	}
+}
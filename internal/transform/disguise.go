@@ -0,0 +1,557 @@
+package transform
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Disguise renders payloads as the output of some other kind of API. Only
+// what is shown changes; the payloads themselves are left as they are.
+type Disguise interface {
+	// Name selects the disguise in config
+	Name() string
+	// Render returns the value to show for a payload, along with the
+	// partial errors of the response it came from, if any
+	Render(p DisguisedPayload, errs []ErrorInfo) interface{}
+}
+
+// Disguises returns the built-in disguises, the default first
+func Disguises() []Disguise {
+	return []Disguise{
+		restDisguise{},
+		graphQLDisguise{},
+		kubernetesDisguise{},
+		elasticsearchDisguise{},
+		stripeDisguise{},
+		awsDisguise{},
+	}
+}
+
+// LookupDisguise returns the built-in disguise with the given name. An
+// empty name selects the default.
+func LookupDisguise(name string) (Disguise, error) {
+	all := Disguises()
+	if name == "" {
+		return all[0], nil
+	}
+
+	names := make([]string, len(all))
+	for i, d := range all {
+		if strings.EqualFold(d.Name(), name) {
+			return d, nil
+		}
+		names[i] = d.Name()
+	}
+	return nil, fmt.Errorf("unknown disguise %q (choose from %s)", name, strings.Join(names, ", "))
+}
+
+// restDisguise shows payloads as they are, as responses of a REST API
+type restDisguise struct{}
+
+func (restDisguise) Name() string { return "rest" }
+
+// payloadWithErrors renders an item with the partial errors of its response
+type payloadWithErrors struct {
+	DisguisedPayload
+	Errors []ErrorInfo `json:"errors"`
+}
+
+func (restDisguise) Render(p DisguisedPayload, errs []ErrorInfo) interface{} {
+	if len(errs) > 0 {
+		return payloadWithErrors{DisguisedPayload: p, Errors: errs}
+	}
+	return p
+}
+
+// graphQLDisguise shows payloads as a Relay-style node query
+type graphQLDisguise struct{}
+
+func (graphQLDisguise) Name() string { return "graphql" }
+
+type graphQLResponse struct {
+	Data       graphQLData    `json:"data"`
+	Errors     []graphQLError `json:"errors,omitempty"`
+	Extensions graphQLCost    `json:"extensions"`
+}
+
+type graphQLData struct {
+	Node object `json:"node"`
+}
+
+type graphQLError struct {
+	Message    string            `json:"message"`
+	Path       []string          `json:"path"`
+	Extensions map[string]string `json:"extensions"`
+}
+
+type graphQLCost struct {
+	Cost struct {
+		RequestedQueryCost int `json:"requestedQueryCost"`
+		ActualQueryCost    int `json:"actualQueryCost"`
+	} `json:"cost"`
+}
+
+func (graphQLDisguise) Render(p DisguisedPayload, errs []ErrorInfo) interface{} {
+	typename := pascalCase(p.Type)
+	node := convertKeys(p.Payload, camelCase).(map[string]interface{})
+	node["__typename"] = typename
+	node["id"] = base64.StdEncoding.EncodeToString([]byte(typename + ":" + p.ID))
+	node["databaseId"] = p.ID
+
+	resp := graphQLResponse{
+		Data: graphQLData{Node: object{lead: []string{"__typename", "id", "databaseId"}, fields: node}},
+	}
+	cost := countFields(p.Payload)
+	resp.Extensions.Cost.RequestedQueryCost = cost
+	resp.Extensions.Cost.ActualQueryCost = cost
+
+	for _, e := range errs {
+		message := e.Title
+		if e.Detail != "" {
+			message = e.Detail
+		}
+		resp.Errors = append(resp.Errors, graphQLError{
+			Message:    message,
+			Path:       []string{"node"},
+			Extensions: map[string]string{"code": errorCode(e)},
+		})
+	}
+	return resp
+}
+
+// kubernetesDisguise shows payloads as events from kubectl get events -o
+// json. Like kubectl's, the keys come out sorted.
+type kubernetesDisguise struct{}
+
+func (kubernetesDisguise) Name() string { return "kubernetes" }
+
+func (kubernetesDisguise) Render(p DisguisedPayload, errs []ErrorInfo) interface{} {
+	hash := digest(p.ID)
+	hexHash := hex.EncodeToString(hash)
+	created := createdAt(p).Format(time.RFC3339)
+	pod := fmt.Sprintf("%s-%s-%s", podName(p), hexHash[:9], hexHash[9:14])
+	host := fmt.Sprintf("ip-10-0-%d-%d.ec2.internal", hash[0]%64, hash[1]%250+2)
+
+	event := convertKeys(rest(p.Payload, "content", "bio", "created_at"), camelCase).(map[string]interface{})
+	event["apiVersion"] = "v1"
+	event["kind"] = "Event"
+	event["metadata"] = map[string]interface{}{
+		"name":              fmt.Sprintf("%s.%s", pod, hexHash[24:40]),
+		"namespace":         "default",
+		"uid":               uuid(hash),
+		"resourceVersion":   fmt.Sprint(new(big.Int).SetBytes(hash[:4])),
+		"creationTimestamp": created,
+	}
+	event["involvedObject"] = map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"name":       pod,
+		"namespace":  "default",
+		"uid":        uuid(digest(pod)),
+	}
+	event["reason"] = pascalCase(p.Type)
+	event["message"] = message(p)
+	event["source"] = map[string]interface{}{"component": "kubelet", "host": host}
+	event["reportingComponent"] = "kubelet"
+	event["reportingInstance"] = host
+	event["firstTimestamp"] = created
+	event["lastTimestamp"] = created
+	event["count"] = 1
+	event["type"] = "Normal"
+	if len(errs) > 0 {
+		event["type"] = "Warning"
+		event["errors"] = convertKeys(errorList(errs), camelCase)
+	}
+	return event
+}
+
+// elasticsearchDisguise shows payloads as a hit from a _search request
+type elasticsearchDisguise struct{}
+
+func (elasticsearchDisguise) Name() string { return "elasticsearch" }
+
+type esResponse struct {
+	Took     int      `json:"took"`
+	TimedOut bool     `json:"timed_out"`
+	Shards   esShards `json:"_shards"`
+	Hits     esHits   `json:"hits"`
+}
+
+type esShards struct {
+	Total      int         `json:"total"`
+	Successful int         `json:"successful"`
+	Skipped    int         `json:"skipped"`
+	Failed     int         `json:"failed"`
+	Failures   []esFailure `json:"failures,omitempty"`
+}
+
+type esFailure struct {
+	Shard  int               `json:"shard"`
+	Index  string            `json:"index"`
+	Reason map[string]string `json:"reason"`
+}
+
+type esHits struct {
+	Total struct {
+		Value    int    `json:"value"`
+		Relation string `json:"relation"`
+	} `json:"total"`
+	MaxScore float64 `json:"max_score"`
+	Hits     []esHit `json:"hits"`
+}
+
+type esHit struct {
+	Index  string                 `json:"_index"`
+	ID     string                 `json:"_id"`
+	Score  float64                `json:"_score"`
+	Source map[string]interface{} `json:"_source"`
+}
+
+func (elasticsearchDisguise) Render(p DisguisedPayload, errs []ErrorInfo) interface{} {
+	created := createdAt(p)
+	index := strings.ReplaceAll(p.Type, "_", "-") + "-" + created.Format("2006.01.02")
+
+	source := rest(p.Payload)
+	source["@timestamp"] = created.Format(time.RFC3339)
+
+	resp := esResponse{Took: int(digest(p.ID)[0]%40) + 2}
+	resp.Shards.Total = max(5, len(errs)+1)
+	resp.Shards.Failed = len(errs)
+	resp.Shards.Successful = resp.Shards.Total - resp.Shards.Failed
+	for i, e := range errs {
+		resp.Shards.Failures = append(resp.Shards.Failures, esFailure{
+			Shard:  i + 1,
+			Index:  index,
+			Reason: map[string]string{"type": strings.ToLower(errorCode(e)), "reason": e.Detail},
+		})
+	}
+	resp.Hits.Total.Value = 1
+	resp.Hits.Total.Relation = "eq"
+	resp.Hits.MaxScore = 1
+	resp.Hits.Hits = []esHit{{Index: index, ID: p.ID, Score: 1, Source: source}}
+	return resp
+}
+
+// stripeDisguise shows payloads as webhook events of a payments API
+type stripeDisguise struct{}
+
+func (stripeDisguise) Name() string { return "stripe" }
+
+type stripeEvent struct {
+	ID              string          `json:"id"`
+	Object          string          `json:"object"`
+	APIVersion      string          `json:"api_version"`
+	Created         int64           `json:"created"`
+	Data            stripeEventData `json:"data"`
+	Livemode        bool            `json:"livemode"`
+	PendingWebhooks int             `json:"pending_webhooks"`
+	Request         stripeRequest   `json:"request"`
+	Type            string          `json:"type"`
+}
+
+type stripeEventData struct {
+	Object object `json:"object"`
+}
+
+type stripeRequest struct {
+	ID             string `json:"id"`
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+// stripeObjects names the object, event and ID prefix for each payload type
+var stripeObjects = map[string][3]string{
+	"status_update":    {"status", "status.created", "st"},
+	"user_profile":     {"account", "account.updated", "acct"},
+	"thread":           {"thread", "thread.updated", "thr"},
+	"revision_history": {"status", "status.updated", "st"},
+}
+
+func (stripeDisguise) Render(p DisguisedPayload, errs []ErrorInfo) interface{} {
+	names, ok := stripeObjects[p.Type]
+	if !ok {
+		names = [3]string{p.Type, p.Type + ".updated", "obj"}
+	}
+	created := createdAt(p).Unix()
+
+	obj := rest(p.Payload, "created_at")
+	obj["id"] = names[2] + "_" + base62(digest(p.ID), 24)
+	obj["object"] = names[0]
+	obj["created"] = created
+	obj["metadata"] = map[string]string{"source_id": p.ID}
+	if len(errs) > 0 {
+		obj["errors"] = errorList(errs)
+	}
+
+	return stripeEvent{
+		ID:         "evt_" + base62(digest("event:"+p.ID), 24),
+		Object:     "event",
+		APIVersion: "2024-06-20",
+		Created:    created,
+		Data: stripeEventData{
+			Object: object{lead: []string{"id", "object"}, fields: obj},
+		},
+		Livemode: true,
+		Request: stripeRequest{
+			ID:             "req_" + base62(digest("request:"+p.ID), 14),
+			IdempotencyKey: uuid(digest(p.Timestamp + p.ID)),
+		},
+		Type: names[1],
+	}
+}
+
+// awsDisguise shows payloads as the output of an AWS CLI describe-* command
+type awsDisguise struct{}
+
+func (awsDisguise) Name() string { return "aws" }
+
+// awsAccount is the account ID in the ARNs of the AWS disguise
+const awsAccount = "418295730164"
+
+func (awsDisguise) Render(p DisguisedPayload, errs []ErrorInfo) interface{} {
+	typename := pascalCase(p.Type)
+	resource := strings.ReplaceAll(p.Type, "_", "-")
+	arn := func(id string) string {
+		return fmt.Sprintf("arn:aws:social:us-east-1:%s:%s/%s", awsAccount, resource, id)
+	}
+
+	item := convertKeys(rest(p.Payload, "created_at"), pascalCase).(map[string]interface{})
+	item[typename+"Id"] = p.ID
+	item[typename+"Arn"] = arn(p.ID)
+	item["CreationTime"] = createdAt(p).Format("2006-01-02T15:04:05.000Z07:00")
+
+	plural := typename + "s"
+	if strings.HasSuffix(typename, "y") {
+		plural = strings.TrimSuffix(typename, "y") + "ies"
+	}
+	out := map[string]interface{}{
+		plural: []interface{}{object{lead: []string{typename + "Id", typename + "Arn", "CreationTime"}, fields: item}},
+	}
+
+	if len(errs) > 0 {
+		failures := make([]interface{}, 0, len(errs))
+		for _, e := range errs {
+			failure := map[string]string{"Reason": errorCode(e), "Detail": e.Detail}
+			if e.Resource != "" {
+				failure["Arn"] = arn(e.Resource)
+			}
+			failures = append(failures, failure)
+		}
+		out["Failures"] = failures
+	}
+	return object{lead: []string{plural}, fields: out}
+}
+
+// object is a JSON object that writes its lead keys first, in order, and
+// the rest sorted, the way most APIs lay out their resources
+type object struct {
+	lead   []string
+	fields map[string]interface{}
+}
+
+func (o object) MarshalJSON() ([]byte, error) {
+	keys := make([]string, 0, len(o.fields))
+	for k := range o.fields {
+		keys = append(keys, k)
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		ri, rj := o.rank(keys[i]), o.rank(keys[j])
+		if ri != rj {
+			return ri < rj
+		}
+		return keys[i] < keys[j]
+	})
+
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, _ := json.Marshal(k)
+		value, err := json.Marshal(o.fields[k])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// rank orders lead keys before all others
+func (o object) rank(key string) int {
+	for i, k := range o.lead {
+		if k == key {
+			return i
+		}
+	}
+	return len(o.lead)
+}
+
+// rest returns a shallow copy of a payload without the given keys
+func rest(payload map[string]interface{}, omit ...string) map[string]interface{} {
+	out := make(map[string]interface{}, len(payload))
+	for k, v := range payload {
+		out[k] = v
+	}
+	for _, k := range omit {
+		delete(out, k)
+	}
+	return out
+}
+
+// convertKeys renames the keys of every object in a payload value
+func convertKeys(v interface{}, rename func(string) string) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			out[rename(k)] = convertKeys(child, rename)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, child := range v {
+			out[i] = convertKeys(child, rename)
+		}
+		return out
+	}
+	return v
+}
+
+// camelCase turns snake_case into camelCase
+func camelCase(s string) string {
+	parts := strings.Split(s, "_")
+	for i := 1; i < len(parts); i++ {
+		parts[i] = capitalize(parts[i])
+	}
+	return strings.Join(parts, "")
+}
+
+// pascalCase turns snake_case into PascalCase
+func pascalCase(s string) string {
+	return capitalize(camelCase(s))
+}
+
+func capitalize(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToUpper(s[:1]) + s[1:]
+}
+
+// errorList converts partial errors to plain objects with snake_case keys
+func errorList(errs []ErrorInfo) []interface{} {
+	out := make([]interface{}, 0, len(errs))
+	for _, e := range errs {
+		item := map[string]interface{}{"title": e.Title}
+		if e.Detail != "" {
+			item["detail"] = e.Detail
+		}
+		if e.Resource != "" {
+			item["resource"] = e.Resource
+		}
+		out = append(out, item)
+	}
+	return out
+}
+
+// errorCode makes an UPPER_SNAKE code from an error's problem type, such as
+// RESOURCE_NOT_FOUND
+func errorCode(e ErrorInfo) string {
+	code := e.Type
+	if i := strings.LastIndex(code, "/"); i >= 0 {
+		code = code[i+1:]
+	}
+	if code == "" {
+		code = e.Title
+	}
+	return strings.ToUpper(strings.NewReplacer("-", "_", " ", "_").Replace(code))
+}
+
+// createdAt returns when the object in a payload was created, or when the
+// payload was made if it doesn't say
+func createdAt(p DisguisedPayload) time.Time {
+	for _, s := range []interface{}{p.Payload["created_at"], p.Timestamp} {
+		if s, ok := s.(string); ok {
+			if t, err := time.Parse(time.RFC3339, s); err == nil && !t.IsZero() {
+				return t.UTC()
+			}
+		}
+	}
+	return time.Now().UTC()
+}
+
+// message returns the text of a payload: a post's content or a bio
+func message(p DisguisedPayload) string {
+	for _, key := range []string{"content", "bio"} {
+		if s, ok := p.Payload[key].(string); ok && s != "" {
+			return s
+		}
+	}
+	return pascalCase(p.Type)
+}
+
+// podName names the pod an event is about after the payload's author
+func podName(p DisguisedPayload) string {
+	handle, _ := p.Payload["handle"].(string)
+	if author, ok := p.Payload["author"].(map[string]interface{}); ok {
+		handle, _ = author["handle"].(string)
+	}
+	if handle == "" {
+		handle = p.Type
+	}
+	return strings.ToLower(strings.ReplaceAll(handle, "_", "-"))
+}
+
+// countFields counts the fields of a payload, nested ones included
+func countFields(v interface{}) int {
+	n := 0
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for _, child := range v {
+			n += 1 + countFields(child)
+		}
+	case []interface{}:
+		for _, child := range v {
+			n += countFields(child)
+		}
+	}
+	return n
+}
+
+// digest hashes a value into stable bytes for generated IDs
+func digest(s string) []byte {
+	sum := sha1.Sum([]byte(s))
+	return sum[:]
+}
+
+// uuid formats the first 16 bytes of a digest as a UUID
+func uuid(hash []byte) string {
+	h := hex.EncodeToString(hash[:16])
+	return fmt.Sprintf("%s-%s-%s-%s-%s", h[:8], h[8:12], h[12:16], h[16:20], h[20:32])
+}
+
+// base62 encodes bytes as n base62 digits, the alphabet of most API IDs
+func base62(b []byte, n int) string {
+	const digits = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+	v := new(big.Int).SetBytes(b)
+	base := big.NewInt(62)
+	mod := new(big.Int)
+	out := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		v.DivMod(v, base, mod)
+		out[i] = digits[mod.Int64()]
+	}
+	return string(out)
+}
@@ -3,6 +3,11 @@ package transform
 import (
 	"encoding/json"
 	"fmt"
+	"math"
+	"mime"
+	"net/url"
+	"path"
+	"sort"
 	"time"
 
 	"github.com/kenan/xjson/internal/api"
@@ -26,6 +31,25 @@ type DisguisedResponse struct {
 	Latency    string             `json:"latency_ms"`
 	Data       []DisguisedPayload `json:"data"`
 	Meta       *MetaInfo          `json:"_meta,omitempty"`
+	Errors     []ErrorInfo        `json:"errors,omitempty"`
+
+	// pages holds the partial errors of pages added by Append
+	pages []pageErrors
+}
+
+// pageErrors are the partial errors of an appended page, whose items start
+// at index start
+type pageErrors struct {
+	start  int
+	errors []ErrorInfo
+}
+
+// ErrorInfo is a partial failure reported alongside the data
+type ErrorInfo struct {
+	Title    string `json:"title"`
+	Detail   string `json:"detail,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Resource string `json:"resource,omitempty"`
 }
 
 // MetaInfo contains pagination metadata
@@ -35,10 +59,129 @@ type MetaInfo struct {
 	HasMore     bool   `json:"has_more"`
 }
 
-// TransformTweet converts a tweet to disguised format
-func TransformTweet(tweet *api.Tweet, author *api.User) DisguisedPayload {
+// transformErrors converts partial errors to disguised format
+func transformErrors(errs []api.ResourceError) []ErrorInfo {
+	if len(errs) == 0 {
+		return nil
+	}
+
+	result := make([]ErrorInfo, 0, len(errs))
+	for _, e := range errs {
+		info := ErrorInfo{
+			Title:  e.Title,
+			Detail: e.Summary(),
+			Type:   e.Type,
+		}
+		if e.ResourceID != "" {
+			info.Resource = fmt.Sprintf("%s:%s", e.ResourceType, e.ResourceID)
+		}
+		result = append(result, info)
+	}
+	return result
+}
+
+// expansions indexes the objects included with a response by ID
+type expansions struct {
+	users  map[string]*api.User
+	tweets map[string]*api.Tweet
+	media  map[string]*api.Media
+	polls  map[string]*api.Poll
+}
+
+func newExpansions(includes *api.Includes) *expansions {
+	e := &expansions{
+		users:  make(map[string]*api.User),
+		tweets: make(map[string]*api.Tweet),
+		media:  make(map[string]*api.Media),
+		polls:  make(map[string]*api.Poll),
+	}
+	if includes == nil {
+		return e
+	}
+	for i := range includes.Users {
+		e.users[includes.Users[i].ID] = &includes.Users[i]
+	}
+	for i := range includes.Tweets {
+		e.tweets[includes.Tweets[i].ID] = &includes.Tweets[i]
+	}
+	for i := range includes.Media {
+		e.media[includes.Media[i].MediaKey] = &includes.Media[i]
+	}
+	for i := range includes.Polls {
+		e.polls[includes.Polls[i].ID] = &includes.Polls[i]
+	}
+	return e
+}
+
+// author returns a user by ID, or a placeholder if it wasn't included
+func (e *expansions) author(id string) *api.User {
+	if user := e.users[id]; user != nil {
+		return user
+	}
+	return &api.User{Username: "unknown", Name: "Unknown User"}
+}
+
+// TransformTweet converts a tweet to disguised format, resolving its author
+// and attachments from the response's includes
+func TransformTweet(tweet *api.Tweet, includes *api.Includes) DisguisedPayload {
+	return transformTweet(tweet, newExpansions(includes))
+}
+
+func transformTweet(tweet *api.Tweet, exp *expansions) DisguisedPayload {
+	return DisguisedPayload{
+		ID:        tweet.ID,
+		Type:      "status_update",
+		Endpoint:  fmt.Sprintf("/v2/statuses/%s", tweet.ID),
+		Status:    200,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Payload:   tweetPayload(tweet, exp),
+	}
+}
+
+// tweetPayload builds the payload object for a tweet, embedding the post
+// it quotes or reposts
+func tweetPayload(tweet *api.Tweet, exp *expansions) map[string]interface{} {
+	payload := tweetBody(tweet, exp)
+	if ref := exp.referenced(tweet); ref != nil {
+		payload["ref"] = ref
+	}
+	return payload
+}
+
+// referenced describes the post a tweet quotes or reposts. Posts that
+// weren't included, such as deleted or protected ones, are listed by ID.
+func (e *expansions) referenced(tweet *api.Tweet) map[string]interface{} {
+	rel, id := "quoted", tweet.QuotedID()
+	if id == "" {
+		rel, id = "reposted", tweet.RetweetedID()
+	}
+	if id == "" {
+		return nil
+	}
+
+	ref := map[string]interface{}{
+		"rel":  rel,
+		"href": fmt.Sprintf("/v2/statuses/%s", id),
+	}
+	if original := e.tweets[id]; original != nil {
+		// Only one level deep, like the expansion itself
+		ref["resource"] = map[string]interface{}{
+			"id":      original.ID,
+			"type":    "status_update",
+			"payload": tweetBody(original, e),
+		}
+	} else {
+		ref["resource"] = nil
+		ref["status"] = 404
+	}
+	return ref
+}
+
+// tweetBody builds the payload of a tweet without any referenced post
+func tweetBody(tweet *api.Tweet, exp *expansions) map[string]interface{} {
+	author := exp.author(tweet.AuthorID)
 	payload := map[string]interface{}{
-		"content": tweet.Text,
+		"content": tweet.FullText(),
 		"author": map[string]interface{}{
 			"handle":       author.Username,
 			"display_name": author.Name,
@@ -57,32 +200,387 @@ func TransformTweet(tweet *api.Tweet, author *api.User) DisguisedPayload {
 		}
 	}
 
+	if tweet.ConversationID != "" {
+		payload["thread_id"] = tweet.ConversationID
+	}
+	if parent := tweet.RepliedToID(); parent != "" {
+		payload["parent_id"] = parent
+	}
+
+	if entities := tweet.FullEntities(); entities != nil {
+		if links := tweetLinks(entities); len(links) > 0 {
+			payload["links"] = links
+		}
+		if refs := tweetRefs(entities); len(refs) > 0 {
+			payload["refs"] = refs
+		}
+	}
+
+	if tweet.Edited() {
+		payload["revisions"] = tweetRevisions(tweet)
+		if latest := tweet.EditHistory[len(tweet.EditHistory)-1]; latest != tweet.ID {
+			payload["superseded_by"] = latest
+		}
+	}
+	if ec := tweet.EditControls; ec != nil && ec.IsEditEligible && ec.EditableUntil.After(time.Now()) {
+		payload["edit_window"] = map[string]interface{}{
+			"closes_at":       ec.EditableUntil.Format(time.RFC3339),
+			"edits_remaining": ec.EditsRemaining,
+		}
+	}
+
+	if tweet.Attachments != nil {
+		if assets := exp.assets(tweet.Attachments.MediaKeys); len(assets) > 0 {
+			payload["assets"] = assets
+		}
+		if survey := exp.survey(tweet.Attachments.PollIDs); survey != nil {
+			payload["survey"] = survey
+		}
+	}
+
+	return payload
+}
+
+// tweetRevisions lists the versions of an edited tweet, oldest first,
+// marking the one this is
+func tweetRevisions(tweet *api.Tweet) []interface{} {
+	revisions := make([]interface{}, len(tweet.EditHistory))
+	for i, id := range tweet.EditHistory {
+		revision := map[string]interface{}{
+			"rev":  i + 1,
+			"id":   id,
+			"href": fmt.Sprintf("/v2/statuses/%s", id),
+		}
+		if id == tweet.ID {
+			revision["current"] = true
+		}
+		revisions[i] = revision
+	}
+	return revisions
+}
+
+// tweetLinks describes the t.co links in a tweet. Links to attached media
+// are left out, as the media is already listed in assets.
+func tweetLinks(entities *api.Entities) []interface{} {
+	var links []interface{}
+	for _, u := range entities.URLs {
+		if u.MediaKey != "" {
+			continue
+		}
+		link := map[string]interface{}{
+			"url":          u.URL,
+			"expanded_url": u.ExpandedURL,
+			"display_url":  u.DisplayURL,
+			"span":         []int{u.Start, u.End},
+		}
+		if u.UnwoundURL != "" && u.UnwoundURL != u.ExpandedURL {
+			link["unwound_url"] = u.UnwoundURL
+		}
+		if u.Title != "" {
+			link["title"] = u.Title
+		}
+		links = append(links, link)
+	}
+	return links
+}
+
+// tweetRefs describes the mentions, hashtags and cashtags in a tweet, in
+// the order they appear in the text
+func tweetRefs(entities *api.Entities) []interface{} {
+	type ref struct {
+		start int
+		value map[string]interface{}
+	}
+	var refs []ref
+	for _, m := range entities.Mentions {
+		value := map[string]interface{}{
+			"kind":   "user",
+			"handle": m.Username,
+			"span":   []int{m.Start, m.End},
+		}
+		if m.ID != "" {
+			value["id"] = m.ID
+		}
+		refs = append(refs, ref{m.Start, value})
+	}
+	for _, tag := range entities.Hashtags {
+		refs = append(refs, ref{tag.Start, map[string]interface{}{
+			"kind": "hashtag",
+			"tag":  tag.Tag,
+			"span": []int{tag.Start, tag.End},
+		}})
+	}
+	for _, tag := range entities.Cashtags {
+		refs = append(refs, ref{tag.Start, map[string]interface{}{
+			"kind": "cashtag",
+			"tag":  tag.Tag,
+			"span": []int{tag.Start, tag.End},
+		}})
+	}
+
+	sort.SliceStable(refs, func(i, j int) bool { return refs[i].start < refs[j].start })
+	out := make([]interface{}, len(refs))
+	for i, r := range refs {
+		out[i] = r.value
+	}
+	return out
+}
+
+// assets describes attached media, skipping keys that weren't included
+func (e *expansions) assets(keys []string) []interface{} {
+	var assets []interface{}
+	for _, key := range keys {
+		m := e.media[key]
+		if m == nil {
+			continue
+		}
+
+		mimeType, uri := mediaSource(m)
+		asset := map[string]interface{}{
+			"id":   m.MediaKey,
+			"kind": mediaKind(m.Type),
+			"mime": mimeType,
+			"uri":  uri,
+		}
+		if m.Width > 0 && m.Height > 0 {
+			asset["dimensions"] = map[string]interface{}{
+				"width":  m.Width,
+				"height": m.Height,
+			}
+		}
+		if m.DurationMs > 0 {
+			asset["duration_ms"] = m.DurationMs
+		}
+		if m.Type != "photo" && m.PreviewImageURL != "" {
+			asset["thumbnail_uri"] = m.PreviewImageURL
+		}
+		if m.AltText != "" {
+			asset["alt"] = m.AltText
+		}
+		assets = append(assets, asset)
+	}
+	return assets
+}
+
+// mediaKind names a media type the way the disguised payload does
+func mediaKind(mediaType string) string {
+	switch mediaType {
+	case "photo":
+		return "image"
+	case "animated_gif":
+		return "gif"
+	}
+	return mediaType
+}
+
+// mediaSource returns the MIME type and URL of a media object: the photo
+// itself, or the highest bitrate MP4 of a video or GIF
+func mediaSource(m *api.Media) (string, string) {
+	if m.Type == "photo" {
+		return contentType(m.URL), m.URL
+	}
+
+	var best *api.MediaVariant
+	for i := range m.Variants {
+		v := &m.Variants[i]
+		if v.ContentType == "video/mp4" && (best == nil || v.BitRate > best.BitRate) {
+			best = v
+		}
+	}
+	if best == nil && len(m.Variants) > 0 {
+		best = &m.Variants[0]
+	}
+	if best != nil {
+		return best.ContentType, best.URL
+	}
+	return contentType(m.PreviewImageURL), m.PreviewImageURL
+}
+
+// contentType guesses a MIME type from a URL's extension
+func contentType(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err == nil {
+		if t := mime.TypeByExtension(path.Ext(u.Path)); t != "" {
+			return t
+		}
+	}
+	return "image/jpeg"
+}
+
+// survey describes the first included poll with its option tallies
+func (e *expansions) survey(ids []string) map[string]interface{} {
+	for _, id := range ids {
+		poll := e.polls[id]
+		if poll == nil {
+			continue
+		}
+
+		total := 0
+		for _, option := range poll.Options {
+			total += option.Votes
+		}
+
+		options := make([]interface{}, 0, len(poll.Options))
+		for _, option := range poll.Options {
+			share := 0.0
+			if total > 0 {
+				share = math.Round(float64(option.Votes)*1000/float64(total)) / 10
+			}
+			options = append(options, map[string]interface{}{
+				"position":  option.Position,
+				"label":     option.Label,
+				"votes":     option.Votes,
+				"share_pct": share,
+			})
+		}
+
+		survey := map[string]interface{}{
+			"id":          poll.ID,
+			"state":       poll.VotingStatus,
+			"total_votes": total,
+			"options":     options,
+		}
+		if poll.DurationMinutes > 0 {
+			survey["duration_minutes"] = poll.DurationMinutes
+		}
+		if !poll.EndDatetime.IsZero() {
+			survey["closes_at"] = poll.EndDatetime.Format(time.RFC3339)
+		}
+		return survey
+	}
+	return nil
+}
+
+// TransformThread converts a conversation into a single payload with the
+// replies nested under the posts they answer. Root may be nil if it could
+// not be fetched, in which case the replies hang off a placeholder.
+func TransformThread(conversationID string, root *api.TweetResponse, resp *api.SearchResponse) DisguisedPayload {
+	exp := newExpansions(resp.Includes)
+
+	// Search may return the root itself; it is rendered from root, not as a reply
+	tweets := make([]api.Tweet, 0, len(resp.Data))
+	for _, tweet := range resp.Data {
+		if tweet.ID != conversationID {
+			tweets = append(tweets, tweet)
+		}
+	}
+	sort.SliceStable(tweets, func(i, j int) bool {
+		return tweets[i].CreatedAt.Before(tweets[j].CreatedAt)
+	})
+
+	known := make(map[string]bool, len(tweets))
+	for _, tweet := range tweets {
+		known[tweet.ID] = true
+	}
+
+	// Group replies by parent; anything whose parent is missing attaches to the root
+	children := make(map[string][]*api.Tweet)
+	for i := range tweets {
+		parent := tweets[i].RepliedToID()
+		if parent == "" || parent == tweets[i].ID || !known[parent] {
+			parent = conversationID
+		}
+		children[parent] = append(children[parent], &tweets[i])
+	}
+
+	var build func(id string) []interface{}
+	build = func(id string) []interface{} {
+		replies := make([]interface{}, 0, len(children[id]))
+		for _, tweet := range children[id] {
+			node := tweetPayload(tweet, exp)
+			node["id"] = tweet.ID
+			if nested := build(tweet.ID); len(nested) > 0 {
+				node["replies"] = nested
+			}
+			replies = append(replies, node)
+		}
+		return replies
+	}
+
+	var payload map[string]interface{}
+	if root != nil {
+		payload = tweetPayload(&root.Data, newExpansions(root.Includes))
+	} else {
+		payload = map[string]interface{}{
+			"thread_id": conversationID,
+			"content":   nil,
+		}
+	}
+	payload["replies"] = build(conversationID)
+	payload["reply_count"] = len(tweets)
+
 	return DisguisedPayload{
-		ID:        tweet.ID,
-		Type:      "status_update",
-		Endpoint:  fmt.Sprintf("/v2/statuses/%s", tweet.ID),
+		ID:        conversationID,
+		Type:      "thread",
+		Endpoint:  fmt.Sprintf("/v2/statuses/%s/thread", conversationID),
 		Status:    200,
 		Timestamp: time.Now().Format(time.RFC3339),
 		Payload:   payload,
 	}
 }
 
-// TransformTimeline converts a timeline response to disguised format
-func TransformTimeline(resp *api.TimelineResponse, endpoint string) *DisguisedResponse {
-	userMap := make(map[string]*api.User)
-	if resp.Includes != nil {
-		for i := range resp.Includes.Users {
-			userMap[resp.Includes.Users[i].ID] = &resp.Includes.Users[i]
+// TransformRevisions converts the versions of an edited post, given oldest
+// first, into a payload that diffs each earlier version against the
+// current one. Versions that could not be fetched are nil.
+func TransformRevisions(ids []string, versions []*api.TweetResponse) DisguisedPayload {
+	latest := ids[len(ids)-1]
+
+	// The newest version that could be fetched is the one diffed against
+	current := -1
+	for i := len(versions) - 1; i >= 0; i-- {
+		if versions[i] != nil {
+			current = i
+			break
 		}
 	}
 
+	revisions := make([]interface{}, 0, len(ids))
+	for i, id := range ids {
+		revision := map[string]interface{}{
+			"rev": i + 1,
+			"id":  id,
+		}
+		if versions[i] == nil {
+			revision["status"] = 404
+			revisions = append(revisions, revision)
+			continue
+		}
+
+		tweet := &versions[i].Data
+		revision["created_at"] = tweet.CreatedAt.Format(time.RFC3339)
+		if i == current {
+			revision["content"] = tweet.FullText()
+		} else {
+			diff, added, removed := wordDiff(tweet.FullText(), versions[current].Data.FullText())
+			revision["diff"] = diff
+			revision["changes"] = map[string]interface{}{
+				"words_added":   added,
+				"words_removed": removed,
+			}
+			revision["diff_to"] = current + 1
+		}
+		revisions = append(revisions, revision)
+	}
+
+	return DisguisedPayload{
+		ID:        latest,
+		Type:      "revision_history",
+		Endpoint:  fmt.Sprintf("/v2/statuses/%s/revisions", latest),
+		Status:    200,
+		Timestamp: time.Now().Format(time.RFC3339),
+		Payload: map[string]interface{}{
+			"current":   latest,
+			"revisions": revisions,
+		},
+	}
+}
+
+// TransformTimeline converts a timeline response to disguised format
+func TransformTimeline(resp *api.TimelineResponse, endpoint string) *DisguisedResponse {
+	exp := newExpansions(resp.Includes)
 	data := make([]DisguisedPayload, 0, len(resp.Data))
 	for _, tweet := range resp.Data {
-		author := userMap[tweet.AuthorID]
-		if author == nil {
-			author = &api.User{Username: "unknown", Name: "Unknown User"}
-		}
-		data = append(data, TransformTweet(&tweet, author))
+		data = append(data, transformTweet(&tweet, exp))
 	}
 
 	result := &DisguisedResponse{
@@ -91,6 +589,7 @@ func TransformTimeline(resp *api.TimelineResponse, endpoint string) *DisguisedRe
 		StatusCode: 200,
 		Latency:    fmt.Sprintf("%d", 50+len(resp.Data)*2),
 		Data:       data,
+		Errors:     transformErrors(resp.Errors),
 	}
 
 	if resp.Meta != nil {
@@ -129,22 +628,26 @@ func TransformUser(user *api.User) DisguisedPayload {
 	}
 }
 
+// TransformProfile converts a user and their recent tweets to disguised
+// format. The user object is the first item, followed by the tweets.
+func TransformProfile(user *api.User, resp *api.TimelineResponse) *DisguisedResponse {
+	endpoint := fmt.Sprintf("/v2/users/%s/statuses", user.ID)
+	tweets := TransformTimeline(resp, endpoint)
+
+	data := make([]DisguisedPayload, 0, len(tweets.Data)+1)
+	data = append(data, TransformUser(user))
+	data = append(data, tweets.Data...)
+	tweets.Data = data
+
+	return tweets
+}
+
 // TransformSearch converts search results to disguised format
 func TransformSearch(resp *api.SearchResponse, query string) *DisguisedResponse {
-	userMap := make(map[string]*api.User)
-	if resp.Includes != nil {
-		for i := range resp.Includes.Users {
-			userMap[resp.Includes.Users[i].ID] = &resp.Includes.Users[i]
-		}
-	}
-
+	exp := newExpansions(resp.Includes)
 	data := make([]DisguisedPayload, 0, len(resp.Data))
 	for _, tweet := range resp.Data {
-		author := userMap[tweet.AuthorID]
-		if author == nil {
-			author = &api.User{Username: "unknown", Name: "Unknown User"}
-		}
-		data = append(data, TransformTweet(&tweet, author))
+		data = append(data, transformTweet(&tweet, exp))
 	}
 
 	result := &DisguisedResponse{
@@ -153,6 +656,7 @@ func TransformSearch(resp *api.SearchResponse, query string) *DisguisedResponse
 		StatusCode: 200,
 		Latency:    fmt.Sprintf("%d", 80+len(resp.Data)*3),
 		Data:       data,
+		Errors:     transformErrors(resp.Errors),
 	}
 
 	if resp.Meta != nil {
@@ -166,6 +670,53 @@ func TransformSearch(resp *api.SearchResponse, query string) *DisguisedResponse
 	return result
 }
 
+// ErrorsFor returns the partial errors of the page the item at index i
+// arrived in
+func (r *DisguisedResponse) ErrorsFor(i int) []ErrorInfo {
+	for j := len(r.pages) - 1; j >= 0; j-- {
+		if i >= r.pages[j].start {
+			return r.pages[j].errors
+		}
+	}
+	return r.Errors
+}
+
+// Append adds the items of a later page, skipping any already present by ID,
+// and takes over the page's pagination metadata. It returns the number of
+// items added.
+func (r *DisguisedResponse) Append(page *DisguisedResponse) int {
+	seen := make(map[string]bool, len(r.Data))
+	for _, item := range r.Data {
+		seen[item.ID] = true
+	}
+
+	r.pages = append(r.pages, pageErrors{start: len(r.Data), errors: page.Errors})
+
+	added := 0
+	for _, item := range page.Data {
+		if seen[item.ID] {
+			continue
+		}
+		seen[item.ID] = true
+		r.Data = append(r.Data, item)
+		added++
+	}
+
+	if page.Meta != nil {
+		r.Meta = &MetaInfo{
+			ResultCount: len(r.Data),
+			NextCursor:  page.Meta.NextCursor,
+			HasMore:     page.Meta.HasMore,
+		}
+	} else if r.Meta != nil {
+		r.Meta.ResultCount = len(r.Data)
+		r.Meta.NextCursor = ""
+		r.Meta.HasMore = false
+	}
+
+	return added
+}
+
 // ToJSON converts a payload to pretty-printed JSON
 func ToJSON(v interface{}) (string, error) {
 	data, err := json.MarshalIndent(v, "", "  ")
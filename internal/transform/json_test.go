@@ -0,0 +1,44 @@
+package transform
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/kenan/xjson/internal/api"
+)
+
+func TestTransformRevisionsDiffsAgainstCurrent(t *testing.T) {
+	ids := []string{"1", "2", "3", "4"}
+	versions := []*api.TweetResponse{
+		{Data: api.Tweet{ID: "1", Text: "shipping v1 on monday"}},
+		{Data: api.Tweet{ID: "2", Text: "shipping v1 on tuesday"}},
+		{Data: api.Tweet{ID: "3", Text: "shipping v1.1 on tuesday"}},
+		nil, // the latest version is gone, so version 3 is the current one
+	}
+
+	payload := TransformRevisions(ids, versions).Payload
+	revisions := payload["revisions"].([]interface{})
+	rev := func(i int) map[string]interface{} {
+		return revisions[i].(map[string]interface{})
+	}
+
+	if got := rev(2)["content"]; got != "shipping v1.1 on tuesday" {
+		t.Fatalf("current content = %v", got)
+	}
+	if rev(3)["status"] != 404 {
+		t.Fatalf("missing version = %v, want status 404", rev(3))
+	}
+
+	// The original is compared with the current text, not with version 2
+	want := []string{"  shipping", "- v1", "+ v1.1", "  on", "- monday", "+ tuesday"}
+	if got := rev(0)["diff"].([]string); !slices.Equal(got, want) {
+		t.Errorf("rev 1 diff = %q, want %q", got, want)
+	}
+	if rev(0)["diff_to"] != 3 || rev(1)["diff_to"] != 3 {
+		t.Errorf("diff_to = %v, %v, want 3", rev(0)["diff_to"], rev(1)["diff_to"])
+	}
+	want = []string{"  shipping", "- v1", "+ v1.1", "  on tuesday"}
+	if got := rev(1)["diff"].([]string); !slices.Equal(got, want) {
+		t.Errorf("rev 2 diff = %q, want %q", got, want)
+	}
+}
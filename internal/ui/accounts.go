@@ -0,0 +1,106 @@
+package ui
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kenan/xjson/internal/api"
+	"github.com/kenan/xjson/internal/transform"
+)
+
+// Account is a named API client the app can switch between
+type Account struct {
+	Name   string
+	Client *api.Client
+}
+
+// session is an account together with the views it had loaded, kept while
+// another account is active
+type session struct {
+	Account
+
+	timeline      *transform.DisguisedResponse
+	profile       *transform.DisguisedResponse
+	searchResults *transform.DisguisedResponse
+	thread        *transform.DisguisedPayload
+	revisions     *transform.DisguisedPayload
+	searchQuery   string
+	history       history
+	view          viewState
+	pending       []pendingRetry
+}
+
+// accountMsg tags a fetch result with the account it was made for
+type accountMsg struct {
+	account int
+	msg     tea.Msg
+}
+
+// forAccount tags the result of cmd with the active account, so results
+// arriving after a switch are not shown under another account
+func (a *App) forAccount(cmd tea.Cmd) tea.Cmd {
+	account := a.active
+	return func() tea.Msg {
+		return accountMsg{account: account, msg: cmd()}
+	}
+}
+
+// switchAccount saves the active account's views and shows the next
+// account, loading its start view if it has nothing on screen yet
+func (a *App) switchAccount() tea.Cmd {
+	if len(a.sessions) < 2 {
+		a.statusLine = "No other accounts configured"
+		return nil
+	}
+
+	cur := &a.sessions[a.active]
+	cur.timeline = a.timeline
+	cur.profile = a.profile
+	cur.searchResults = a.searchResults
+	cur.thread = a.thread
+	cur.revisions = a.revisions
+	cur.searchQuery = a.searchQuery
+	cur.history = a.history
+	cur.view = a.snapshot()
+	cur.pending = a.pending
+
+	a.active = (a.active + 1) % len(a.sessions)
+	next := &a.sessions[a.active]
+	a.client = next.Client
+	a.timeline = next.timeline
+	a.profile = next.profile
+	a.searchResults = next.searchResults
+	a.thread = next.thread
+	a.revisions = next.revisions
+	a.searchQuery = next.searchQuery
+	a.history = next.history
+
+	// Requests in flight belong to the old account, whose pending retries
+	// wait in its session until it is switched back to
+	a.loading = false
+	a.pending = next.pending
+	next.pending = nil
+	a.loadingMore = a.hasPendingPage()
+	a.tickID++
+	a.applyCapabilities()
+
+	var countdown tea.Cmd
+	if len(a.pending) > 0 {
+		countdown = a.countdownTick()
+	}
+
+	if next.view.list == nil && next.view.thread == nil && next.view.revisions == nil {
+		a.mode = viewTimeline
+		a.currentIndex = 0
+		a.err = nil
+		a.tree.Top()
+		a.updateContent()
+		cmd := a.startView()
+		a.statusLine = fmt.Sprintf("Switched to %s - %s", next.Name, a.statusLine)
+		return tea.Batch(cmd, countdown)
+	}
+
+	a.restore(next.view)
+	a.statusLine = fmt.Sprintf("Switched to %s - %s", next.Name, a.statusLine)
+	return countdown
+}
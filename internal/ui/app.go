@@ -2,7 +2,9 @@ package ui
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/help"
@@ -22,109 +24,341 @@ const (
 	viewTimeline viewMode = iota
 	viewProfile
 	viewSearch
+	viewThread
+	viewRevisions
+)
+
+// Input prompts
+type promptKind int
+
+const (
+	promptNone promptKind = iota
+	promptSearch
+	promptHandle
+	promptRef
 )
 
 // App is the main application model
 type App struct {
 	client   *api.Client
+	sessions []session
+	active   int
 	keys     KeyMap
 	help     help.Model
 	viewport viewport.Model
 	input    textinput.Model
+	tree     treeView
+	history  history
 
 	// State
 	mode          viewMode
 	ready         bool
-	searching     bool
+	prompt        promptKind
 	loading       bool
+	loadingMore   bool
 	err           error
+	pending       []pendingRetry
+	tickID        int
+	retries       chan retryMsg
 	width         int
 	height        int
 
 	// Data
 	timeline      *transform.DisguisedResponse
-	profile       *transform.DisguisedPayload
+	profile       *transform.DisguisedResponse
 	searchResults *transform.DisguisedResponse
+	thread        *transform.DisguisedPayload
+	revisions     *transform.DisguisedPayload
+	searchQuery   string
 	currentIndex  int
-	nextToken     string
+	appOnlyQuery  string
 
 	// Display
+	disguise      transform.Disguise
 	jsonContent   string
 	statusLine    string
 }
 
-// NewApp creates a new application instance
-func NewApp(client *api.Client) *App {
+// NewApp creates a new application instance. The first account is shown
+// on start; the others can be switched to in the app.
+func NewApp(accounts ...Account) *App {
 	ti := textinput.New()
-	ti.Placeholder = "search query..."
 	ti.CharLimit = 256
 
-	return &App{
-		client:     client,
+	// Report retries from fetch goroutines back into the update loop
+	retries := make(chan retryMsg, 8)
+	onRetry := func(endpoint string, attempt, maxAttempts int, err error) {
+		select {
+		case retries <- retryMsg{endpoint: endpoint, attempt: attempt, maxAttempts: maxAttempts, err: err}:
+		default:
+		}
+	}
+
+	sessions := make([]session, len(accounts))
+	for i, acct := range accounts {
+		policy := acct.Client.RetryPolicy()
+		policy.OnRetry = onRetry
+		acct.Client.SetRetryPolicy(policy)
+		sessions[i] = session{Account: acct}
+	}
+
+	a := &App{
+		client:     accounts[0].Client,
+		sessions:   sessions,
 		keys:       DefaultKeyMap(),
 		help:       help.New(),
 		input:      ti,
+		tree:       newTreeView(),
+		retries:    retries,
+		disguise:   transform.Disguises()[0],
 		statusLine: "Initializing...",
 	}
+	a.applyCapabilities()
+	return a
+}
+
+// SetDisguise sets how items are rendered
+func (a *App) SetDisguise(d transform.Disguise) {
+	a.disguise = d
+}
+
+// nextDisguise switches to the next built-in disguise
+func (a *App) nextDisguise() {
+	all := transform.Disguises()
+	next := 0
+	for i, d := range all {
+		if d.Name() == a.disguise.Name() {
+			next = (i + 1) % len(all)
+		}
+	}
+	a.disguise = all[next]
+	a.statusLine = "Disguise: " + a.disguise.Name()
+	a.tree.Top()
+	a.updateContent()
+}
+
+// SetAppOnlyQuery sets the search shown on start for accounts with app-only
+// auth, which have no home timeline
+func (a *App) SetAppOnlyQuery(query string) {
+	a.appOnlyQuery = query
+}
+
+// appOnlyHint is shown when an app-only account has nothing to start with
+const appOnlyHint = "App-only auth: no home timeline. Press / to search or @ to open a profile (or set app_only in xjson.yaml)"
+
+// applyCapabilities hides the actions the active account can't perform
+// from the help bar
+func (a *App) applyCapabilities() {
+	a.keys.Timeline.SetEnabled(a.client.Capabilities().UserContext)
+}
+
+// startView loads the view an account opens in: the home timeline, or for
+// app-only auth the current or configured search
+func (a *App) startView() tea.Cmd {
+	if a.client.Capabilities().UserContext {
+		a.loading = true
+		a.statusLine = "GET /2/timeline/home..."
+		return a.fetchTimeline()
+	}
+
+	query := a.searchQuery
+	if query == "" {
+		query = a.appOnlyQuery
+	}
+	if query == "" {
+		a.statusLine = appOnlyHint
+		return nil
+	}
+	a.loading = true
+	a.searchQuery = query
+	a.statusLine = fmt.Sprintf("GET /2/tweets/search/recent?q=%s...", query)
+	return a.searchTweets(query)
 }
 
 // Message types
 type (
 	timelineMsg    *transform.DisguisedResponse
-	profileMsg     *transform.DisguisedPayload
+	profileMsg     *transform.DisguisedResponse
 	searchMsg      *transform.DisguisedResponse
+	threadMsg      *transform.DisguisedPayload
+	revisionsMsg   *transform.DisguisedPayload
 	errMsg         error
 )
 
+// retryMsg reports that the client is retrying a failed request
+type retryMsg struct {
+	endpoint    string
+	attempt     int
+	maxAttempts int
+	err         error
+}
+
+// pageMsg carries an additional page for an already loaded list
+type pageMsg struct {
+	target *transform.DisguisedResponse
+	page   *transform.DisguisedResponse
+}
+
+// prefetchThreshold is how close to the end of a list the next page is requested
+const prefetchThreshold = 3
+
 // Init initializes the app
 func (a *App) Init() tea.Cmd {
-	return a.fetchTimeline()
+	return tea.Batch(a.startView(), waitForRetry(a.retries))
+}
+
+// waitForRetry delivers the next retry notification from the client
+func waitForRetry(retries chan retryMsg) tea.Cmd {
+	return func() tea.Msg {
+		return <-retries
+	}
 }
 
 // fetchTimeline fetches the home timeline
 func (a *App) fetchTimeline() tea.Cmd {
-	return func() tea.Msg {
+	client := a.client
+	return a.forAccount(retryOnRateLimit(func() tea.Msg {
 		ctx := context.Background()
-		resp, err := a.client.GetHomeTimeline(ctx, 20, a.nextToken)
+		resp, err := client.GetHomeTimeline(ctx, 20, "")
 		if err != nil {
 			return errMsg(err)
 		}
 
 		disguised := transform.TransformTimeline(resp, "/2/timeline/home")
-		if resp.Meta != nil {
-			a.nextToken = resp.Meta.NextToken
-		}
-
 		return timelineMsg(disguised)
-	}
+	}))
 }
 
-// fetchProfile fetches a user profile
+// fetchProfile fetches a user profile followed by their recent tweets
 func (a *App) fetchProfile(username string) tea.Cmd {
-	return func() tea.Msg {
+	client := a.client
+	return a.forAccount(retryOnRateLimit(func() tea.Msg {
 		ctx := context.Background()
-		user, err := a.client.GetUser(ctx, username)
+		user, err := client.GetUser(ctx, username)
 		if err != nil {
 			return errMsg(err)
 		}
 
-		disguised := transform.TransformUser(user)
-		return profileMsg(&disguised)
-	}
+		resp, err := client.GetUserTweets(ctx, user.ID, 20, "")
+		if err != nil {
+			return errMsg(err)
+		}
+
+		return profileMsg(transform.TransformProfile(user, resp))
+	}))
 }
 
 // searchTweets searches for tweets
 func (a *App) searchTweets(query string) tea.Cmd {
-	return func() tea.Msg {
+	client := a.client
+	return a.forAccount(retryOnRateLimit(func() tea.Msg {
 		ctx := context.Background()
-		resp, err := a.client.SearchTweets(ctx, query, 20, "")
+		resp, err := client.SearchTweets(ctx, query, 20, "")
 		if err != nil {
 			return errMsg(err)
 		}
 
 		disguised := transform.TransformSearch(resp, query)
 		return searchMsg(disguised)
+	}))
+}
+
+// fetchThread fetches a conversation's root tweet and its replies
+func (a *App) fetchThread(conversationID string) tea.Cmd {
+	client := a.client
+	return a.forAccount(retryOnRateLimit(func() tea.Msg {
+		ctx := context.Background()
+
+		// The root may be too old for recent search, so look it up directly
+		root, err := client.GetTweet(ctx, conversationID)
+		if err != nil {
+			var rateLimit *api.RateLimitError
+			if errors.As(err, &rateLimit) {
+				return errMsg(err)
+			}
+			root = nil
+		}
+
+		resp, err := client.GetConversation(ctx, conversationID)
+		if err != nil {
+			return errMsg(err)
+		}
+
+		disguised := transform.TransformThread(conversationID, root, resp)
+		return threadMsg(&disguised)
+	}))
+}
+
+// fetchRevisions looks up every version of an edited post, oldest first
+func (a *App) fetchRevisions(ids []string) tea.Cmd {
+	client := a.client
+	return a.forAccount(retryOnRateLimit(func() tea.Msg {
+		ctx := context.Background()
+
+		versions := make([]*api.TweetResponse, len(ids))
+		for i, id := range ids {
+			version, err := client.GetTweet(ctx, id)
+			if err != nil {
+				// Versions that were deleted or are hidden are listed without
+				// content; anything else fails the whole lookup
+				var apiErr *api.APIError
+				if errors.As(err, &apiErr) && apiErr.NotFound() {
+					continue
+				}
+				return errMsg(err)
+			}
+			versions[i] = version
+		}
+
+		disguised := transform.TransformRevisions(ids, versions)
+		return revisionsMsg(&disguised)
+	}))
+}
+
+// fetchNextPage requests the page after the current list using its stored cursor
+func (a *App) fetchNextPage() tea.Cmd {
+	list := a.currentList()
+	if a.loadingMore || list == nil || list.Meta == nil || !list.Meta.HasMore {
+		return nil
+	}
+
+	a.loadingMore = true
+	client := a.client
+	mode := a.mode
+	query := a.searchQuery
+	cursor := list.Meta.NextCursor
+	userID := ""
+	if mode == viewProfile && len(list.Data) > 0 {
+		userID = list.Data[0].ID
 	}
+
+	return a.forAccount(retryPageOnRateLimit(func() tea.Msg {
+		ctx := context.Background()
+
+		var page *transform.DisguisedResponse
+		switch mode {
+		case viewTimeline:
+			resp, err := client.GetHomeTimeline(ctx, 20, cursor)
+			if err != nil {
+				return errMsg(err)
+			}
+			page = transform.TransformTimeline(resp, "/2/timeline/home")
+		case viewSearch:
+			resp, err := client.SearchTweets(ctx, query, 20, cursor)
+			if err != nil {
+				return errMsg(err)
+			}
+			page = transform.TransformSearch(resp, query)
+		case viewProfile:
+			resp, err := client.GetUserTweets(ctx, userID, 20, cursor)
+			if err != nil {
+				return errMsg(err)
+			}
+			page = transform.TransformTimeline(resp, fmt.Sprintf("/v2/users/%s/statuses", userID))
+		}
+
+		return pageMsg{target: list, page: page}
+	}))
 }
 
 // Update handles messages
@@ -160,18 +394,30 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.updateContent()
 
 	case tea.KeyMsg:
-		if a.searching {
+		if a.prompt != promptNone {
 			switch {
 			case msg.String() == "enter":
-				a.searching = false
-				query := a.input.Value()
-				if query != "" {
+				kind := a.prompt
+				a.prompt = promptNone
+				value := strings.TrimSpace(a.input.Value())
+				if value == "" {
+					break
+				}
+				switch kind {
+				case promptSearch:
 					a.loading = true
-					a.statusLine = fmt.Sprintf("GET /2/tweets/search/recent?q=%s...", query)
-					return a, a.searchTweets(query)
+					a.searchQuery = value
+					a.statusLine = fmt.Sprintf("GET /2/tweets/search/recent?q=%s...", value)
+					return a, a.searchTweets(value)
+				case promptHandle:
+					a.input.Reset()
+					return a, a.openProfile(strings.TrimPrefix(value, "@"))
+				case promptRef:
+					a.input.Reset()
+					return a, a.openRef(value)
 				}
 			case msg.String() == "esc":
-				a.searching = false
+				a.prompt = promptNone
 				a.input.Reset()
 			default:
 				var cmd tea.Cmd
@@ -181,14 +427,20 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return a, nil
 		}
 
+		// Disabled bindings don't match, so catch them to say why
+		if !a.keys.Timeline.Enabled() && slices.Contains(a.keys.Timeline.Keys(), msg.String()) {
+			a.statusLine = "The home timeline needs a signed-in user; this account uses app-only auth"
+			return a, nil
+		}
+
 		switch {
 		case key.Matches(msg, a.keys.Quit):
 			return a, tea.Quit
 
 		case key.Matches(msg, a.keys.Next):
-			a.nextItem()
+			cmd := a.nextItem()
 			a.updateContent()
-			return a, nil
+			return a, cmd
 
 		case key.Matches(msg, a.keys.Prev):
 			a.prevItem()
@@ -196,27 +448,155 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return a, nil
 
 		case key.Matches(msg, a.keys.Refresh):
-			a.loading = true
-			a.currentIndex = 0
-			a.nextToken = ""
-			a.statusLine = "GET /2/timeline/home..."
-			return a, a.fetchTimeline()
+			return a, a.startView()
 
 		case key.Matches(msg, a.keys.Search):
-			a.searching = true
+			a.prompt = promptSearch
+			a.input.Placeholder = "search query..."
+			a.input.SetValue(a.searchQuery)
+			a.input.Focus()
+			return a, textinput.Blink
+
+		case key.Matches(msg, a.keys.Handle):
+			a.prompt = promptHandle
+			a.input.Placeholder = "username..."
+			a.input.Reset()
+			a.input.Focus()
+			return a, textinput.Blink
+
+		case key.Matches(msg, a.keys.Profile):
+			item := a.currentItem()
+			if item == nil || item.Type != "status_update" || a.mode == viewProfile {
+				return a, nil
+			}
+			author, _ := item.Payload["author"].(map[string]interface{})
+			handle, _ := author["handle"].(string)
+			if handle == "" || handle == "unknown" {
+				return a, nil
+			}
+			return a, a.openProfile(handle)
+
+		case key.Matches(msg, a.keys.OpenRef):
+			refs := a.currentRefs()
+			switch len(refs) {
+			case 0:
+				a.statusLine = "No mentions, hashtags or cashtags in this item"
+				return a, nil
+			case 1:
+				return a, a.openRef(refs[0])
+			}
+			// Offer the first and list the rest, which can be typed over it
+			a.prompt = promptRef
+			a.input.Placeholder = ""
+			a.input.SetValue(refs[0])
 			a.input.Focus()
+			a.statusLine = "Open: " + strings.Join(refs, "  ")
 			return a, textinput.Blink
 
 		case key.Matches(msg, a.keys.Timeline):
-			a.mode = viewTimeline
+			if a.timeline == nil {
+				a.loading = true
+				a.statusLine = "GET /2/timeline/home..."
+				return a, a.fetchTimeline()
+			}
+			if a.mode == viewTimeline {
+				return a, nil
+			}
+			// Reuse the loaded timeline, where it was left, rather than
+			// spending a request
+			view, ok := a.history.lastViewOf(a.timeline)
+			if !ok {
+				view = viewState{mode: viewTimeline, list: a.timeline}
+			}
+			a.pushHistory()
+			a.restore(view)
+			return a, nil
+
+		case key.Matches(msg, a.keys.Escape):
+			if prev, ok := a.history.goBack(a.snapshot()); ok {
+				a.restore(prev)
+			}
+			return a, nil
+
+		case key.Matches(msg, a.keys.Forward):
+			if next, ok := a.history.goForward(a.snapshot()); ok {
+				a.restore(next)
+			}
+			return a, nil
+
+		case key.Matches(msg, a.keys.Thread):
+			item := a.currentItem()
+			if item == nil {
+				return a, nil
+			}
+			conversationID, _ := item.Payload["thread_id"].(string)
+			if conversationID == "" {
+				conversationID = item.ID
+			}
+			a.loading = true
+			a.statusLine = fmt.Sprintf("GET /v2/statuses/%s/thread...", conversationID)
+			return a, a.fetchThread(conversationID)
+
+		case key.Matches(msg, a.keys.Revisions):
+			ids := a.currentRevisionIDs()
+			if len(ids) < 2 {
+				a.statusLine = "This post has not been edited"
+				return a, nil
+			}
 			a.loading = true
-			a.currentIndex = 0
-			a.statusLine = "GET /2/timeline/home..."
-			return a, a.fetchTimeline()
+			a.statusLine = fmt.Sprintf("GET /v2/statuses/%s/revisions...", ids[len(ids)-1])
+			return a, a.fetchRevisions(ids)
+
+		case key.Matches(msg, a.keys.Account):
+			return a, a.switchAccount()
+
+		case key.Matches(msg, a.keys.Disguise):
+			a.nextDisguise()
+			return a, nil
 
 		case key.Matches(msg, a.keys.Help):
 			a.help.ShowAll = !a.help.ShowAll
 			return a, nil
+
+		case key.Matches(msg, a.keys.Up):
+			a.tree.MoveCursor(-1)
+			a.renderTree()
+			return a, nil
+
+		case key.Matches(msg, a.keys.Down):
+			a.tree.MoveCursor(1)
+			a.renderTree()
+			return a, nil
+
+		case key.Matches(msg, a.keys.PageUp):
+			a.tree.MoveCursor(-a.viewport.Height)
+			a.renderTree()
+			return a, nil
+
+		case key.Matches(msg, a.keys.PageDown):
+			a.tree.MoveCursor(a.viewport.Height)
+			a.renderTree()
+			return a, nil
+
+		case key.Matches(msg, a.keys.Home):
+			a.tree.Top()
+			a.renderTree()
+			return a, nil
+
+		case key.Matches(msg, a.keys.End):
+			a.tree.Bottom()
+			a.renderTree()
+			return a, nil
+
+		case key.Matches(msg, a.keys.Expand):
+			a.tree.Expand()
+			a.renderTree()
+			return a, nil
+
+		case key.Matches(msg, a.keys.Collapse):
+			a.tree.Collapse()
+			a.renderTree()
+			return a, nil
 		}
 
 		// Pass other keys to viewport for scrolling
@@ -224,29 +604,114 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		a.viewport, cmd = a.viewport.Update(msg)
 		return a, cmd
 
+	case accountMsg:
+		if msg.account != a.active {
+			// The account was switched away from while this was in flight;
+			// a rate-limited fetch is still retried once it is back
+			if limited, ok := msg.msg.(rateLimitMsg); ok {
+				other := &a.sessions[msg.account]
+				other.pending = queueRetry(other.pending, limited)
+			}
+			break
+		}
+		return a.Update(msg.msg)
+
 	case timelineMsg:
 		a.loading = false
+		a.err = nil
+		a.pushHistory()
 		a.mode = viewTimeline
 		a.timeline = msg
+		a.currentIndex = 0
 		a.statusLine = fmt.Sprintf("GET %s - 200 OK (%sms)", msg.Endpoint, msg.Latency)
+		a.tree.Top()
 		a.updateContent()
 
 	case profileMsg:
 		a.loading = false
+		a.err = nil
+		a.pushHistory()
 		a.mode = viewProfile
 		a.profile = msg
-		a.statusLine = fmt.Sprintf("GET %s - 200 OK", msg.Endpoint)
+		a.currentIndex = 0
+		a.statusLine = fmt.Sprintf("GET %s - 200 OK (%sms)", msg.Endpoint, msg.Latency)
+		a.tree.Top()
 		a.updateContent()
 
 	case searchMsg:
 		a.loading = false
+		a.err = nil
+		a.pushHistory()
 		a.mode = viewSearch
 		a.searchResults = msg
+		a.currentIndex = 0
 		a.statusLine = fmt.Sprintf("GET %s - 200 OK (%sms)", msg.Endpoint, msg.Latency)
+		a.tree.Top()
+		a.updateContent()
+
+	case threadMsg:
+		a.loading = false
+		a.err = nil
+		a.pushHistory()
+		a.mode = viewThread
+		a.thread = msg
+		a.statusLine = fmt.Sprintf("GET %s - 200 OK", msg.Endpoint)
+		a.tree.Top()
+		a.updateContent()
+
+	case revisionsMsg:
+		a.loading = false
+		a.err = nil
+		a.pushHistory()
+		a.mode = viewRevisions
+		a.revisions = msg
+		a.statusLine = fmt.Sprintf("GET %s - 200 OK", msg.Endpoint)
+		a.tree.Top()
 		a.updateContent()
 
+	case pageMsg:
+		a.loadingMore = false
+		a.err = nil
+		if msg.page == nil || !a.keepsList(msg.target) {
+			// The list was replaced while the page was in flight
+			break
+		}
+		added := msg.target.Append(msg.page)
+		a.statusLine = fmt.Sprintf("GET %s - 200 OK (%sms) +%d", msg.page.Endpoint, msg.page.Latency, added)
+
+	case retryMsg:
+		a.statusLine = fmt.Sprintf("GET %s - %v - retry %d/%d", msg.endpoint, msg.err, msg.attempt, msg.maxAttempts)
+		return a, waitForRetry(a.retries)
+
+	case rateLimitMsg:
+		a.loading = false
+		// A page waiting for its retry still counts as loading, so n doesn't
+		// queue a second request for it
+		if msg.prefetch {
+			a.loadingMore = true
+		}
+		a.err = msg.err
+		a.pending = queueRetry(a.pending, msg)
+		a.tickID++
+		a.statusLine = a.countdownLine()
+		return a, a.countdownTick()
+
+	case rateLimitTickMsg:
+		if int(msg) != a.tickID || len(a.pending) == 0 {
+			break
+		}
+		cmds = a.dueRetries()
+		if len(a.pending) > 0 {
+			a.statusLine = a.countdownLine()
+			cmds = append(cmds, a.countdownTick())
+		} else {
+			a.err = nil
+			a.statusLine = "Rate limit reset - retrying..."
+		}
+
 	case errMsg:
 		a.loading = false
+		a.loadingMore = false
 		a.err = msg
 		a.statusLine = fmt.Sprintf("Error: %v", msg)
 	}
@@ -254,58 +719,246 @@ func (a *App) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return a, tea.Batch(cmds...)
 }
 
-// nextItem moves to the next item
-func (a *App) nextItem() {
-	var maxIndex int
+// currentList returns the list backing the current view, if any
+func (a *App) currentList() *transform.DisguisedResponse {
 	switch a.mode {
 	case viewTimeline:
-		if a.timeline != nil {
-			maxIndex = len(a.timeline.Data) - 1
-		}
+		return a.timeline
 	case viewSearch:
-		if a.searchResults != nil {
-			maxIndex = len(a.searchResults.Data) - 1
+		return a.searchResults
+	case viewProfile:
+		return a.profile
+	}
+	return nil
+}
+
+// keepsList reports whether a list is still on screen or in the history,
+// so a page loaded for it is worth appending
+func (a *App) keepsList(list *transform.DisguisedResponse) bool {
+	if list == nil {
+		return false
+	}
+	if list == a.timeline || list == a.searchResults || list == a.profile {
+		return true
+	}
+	return a.history.holds(list)
+}
+
+// currentDocument returns the single payload backing a thread or revisions
+// view, if that is what is shown
+func (a *App) currentDocument() *transform.DisguisedPayload {
+	switch a.mode {
+	case viewThread:
+		return a.thread
+	case viewRevisions:
+		return a.revisions
+	}
+	return nil
+}
+
+// snapshot captures the current view for the history stack
+func (a *App) snapshot() viewState {
+	return viewState{
+		mode:        a.mode,
+		list:        a.currentList(),
+		thread:      a.thread,
+		revisions:   a.revisions,
+		searchQuery: a.searchQuery,
+		index:       a.currentIndex,
+		cursor:      a.tree.cursor,
+		offset:      a.viewport.YOffset,
+	}
+}
+
+// pushHistory records the current view before it is replaced
+func (a *App) pushHistory() {
+	if a.currentList() == nil && a.currentDocument() == nil {
+		return
+	}
+	a.history.push(a.snapshot())
+}
+
+// restore puts a remembered view back on screen without refetching
+func (a *App) restore(s viewState) {
+	a.mode = s.mode
+	switch s.mode {
+	case viewTimeline:
+		a.timeline = s.list
+	case viewSearch:
+		a.searchResults = s.list
+		a.searchQuery = s.searchQuery
+	case viewProfile:
+		a.profile = s.list
+	case viewThread:
+		a.thread = s.thread
+	case viewRevisions:
+		a.revisions = s.revisions
+	}
+
+	a.currentIndex = s.index
+	if s.list != nil && a.currentIndex >= len(s.list.Data) {
+		a.currentIndex = 0
+	}
+	a.err = nil
+	a.tree.cursor = s.cursor
+	a.updateContent()
+	a.viewport.SetYOffset(s.offset)
+
+	if s.list != nil {
+		a.statusLine = fmt.Sprintf("GET %s - 200 OK (cached)", s.list.Endpoint)
+	} else if doc := a.currentDocument(); doc != nil {
+		a.statusLine = fmt.Sprintf("GET %s - 200 OK (cached)", doc.Endpoint)
+	}
+}
+
+// rateLimitEndpoint returns the endpoint a view's data is loaded from
+func rateLimitEndpoint(mode viewMode) string {
+	switch mode {
+	case viewProfile:
+		return api.EndpointUserTweets
+	case viewSearch, viewThread:
+		return api.EndpointSearch
+	case viewRevisions:
+		return api.EndpointTweet
+	}
+	return api.EndpointHomeTimeline
+}
+
+// openProfile starts loading a user's profile view
+func (a *App) openProfile(handle string) tea.Cmd {
+	a.loading = true
+	a.statusLine = fmt.Sprintf("GET /v2/users/by/username/%s...", handle)
+	return a.fetchProfile(handle)
+}
+
+// openRef opens a reference from a tweet: a profile for @handle, or a
+// search for #hashtag, $cashtag or anything else
+func (a *App) openRef(ref string) tea.Cmd {
+	if handle, ok := strings.CutPrefix(ref, "@"); ok {
+		return a.openProfile(handle)
+	}
+	a.loading = true
+	a.searchQuery = ref
+	a.statusLine = fmt.Sprintf("GET /2/tweets/search/recent?q=%s...", ref)
+	return a.searchTweets(ref)
+}
+
+// currentPayload returns the payload of the item under view, or of the
+// root post in a thread
+func (a *App) currentPayload() map[string]interface{} {
+	if item := a.currentItem(); item != nil {
+		return item.Payload
+	}
+	if a.mode == viewThread && a.thread != nil {
+		return a.thread.Payload
+	}
+	return nil
+}
+
+// currentRevisionIDs returns the IDs of every version of the post under
+// view, oldest first
+func (a *App) currentRevisionIDs() []string {
+	revisions, _ := a.currentPayload()["revisions"].([]interface{})
+	var ids []string
+	for _, r := range revisions {
+		revision, _ := r.(map[string]interface{})
+		if id, ok := revision["id"].(string); ok {
+			ids = append(ids, id)
 		}
 	}
+	return ids
+}
 
-	if a.currentIndex < maxIndex {
+// currentRefs returns the mentions, hashtags and cashtags of the item under
+// view, or of the root post in a thread, as @handle, #tag and $TAG
+func (a *App) currentRefs() []string {
+	refs, _ := a.currentPayload()["refs"].([]interface{})
+	var out []string
+	for _, r := range refs {
+		ref, _ := r.(map[string]interface{})
+		var value string
+		switch ref["kind"] {
+		case "user":
+			value = "@" + fmt.Sprint(ref["handle"])
+		case "hashtag":
+			value = "#" + fmt.Sprint(ref["tag"])
+		case "cashtag":
+			value = "$" + fmt.Sprint(ref["tag"])
+		default:
+			continue
+		}
+		if !slices.Contains(out, value) {
+			out = append(out, value)
+		}
+	}
+	return out
+}
+
+// currentItem returns the tweet item under view in a list mode, if any
+func (a *App) currentItem() *transform.DisguisedPayload {
+	list := a.currentList()
+	if list == nil || a.currentIndex >= len(list.Data) {
+		return nil
+	}
+	return &list.Data[a.currentIndex]
+}
+
+// nextItem moves to the next item, loading the next page when near the end
+func (a *App) nextItem() tea.Cmd {
+	list := a.currentList()
+	if list == nil {
+		return nil
+	}
+
+	if a.currentIndex < len(list.Data)-1 {
 		a.currentIndex++
+		a.tree.Top()
+	}
+
+	if a.currentIndex >= len(list.Data)-prefetchThreshold {
+		return a.fetchNextPage()
 	}
+	return nil
 }
 
 // prevItem moves to the previous item
 func (a *App) prevItem() {
 	if a.currentIndex > 0 {
 		a.currentIndex--
+		a.tree.Top()
 	}
 }
 
 // updateContent updates the viewport content
 func (a *App) updateContent() {
-	var content string
-	var err error
+	var value interface{}
 
-	switch a.mode {
-	case viewTimeline:
-		if a.timeline != nil && len(a.timeline.Data) > 0 {
-			content, err = transform.ToJSON(a.timeline.Data[a.currentIndex])
-		}
-	case viewProfile:
-		if a.profile != nil {
-			content, err = transform.ToJSON(a.profile)
-		}
-	case viewSearch:
-		if a.searchResults != nil && len(a.searchResults.Data) > 0 {
-			content, err = transform.ToJSON(a.searchResults.Data[a.currentIndex])
-		}
+	if item := a.currentItem(); item != nil {
+		// Show the partial errors of the item's page next to it, as the API would
+		value = a.disguise.Render(*item, a.currentList().ErrorsFor(a.currentIndex))
+	} else if doc := a.currentDocument(); doc != nil {
+		value = a.disguise.Render(*doc, nil)
 	}
 
-	if err != nil {
-		content = fmt.Sprintf("Error rendering JSON: %v", err)
+	if err := a.tree.SetValue(value); err != nil {
+		a.jsonContent = fmt.Sprintf("Error rendering JSON: %v", err)
+		a.viewport.SetContent(a.jsonContent)
+		return
 	}
 
-	a.jsonContent = highlightJSON(content)
+	a.renderTree()
+}
+
+// renderTree redraws the tree view and scrolls the cursor line into view
+func (a *App) renderTree() {
+	a.jsonContent = a.tree.Render()
 	a.viewport.SetContent(a.jsonContent)
+
+	if a.tree.cursor < a.viewport.YOffset {
+		a.viewport.SetYOffset(a.tree.cursor)
+	} else if a.tree.cursor >= a.viewport.YOffset+a.viewport.Height {
+		a.viewport.SetYOffset(a.tree.cursor - a.viewport.Height + 1)
+	}
 }
 
 // highlightJSON applies syntax highlighting to JSON
@@ -435,7 +1088,16 @@ func (a *App) View() string {
 	var b strings.Builder
 
 	// Title bar
-	title := TitleStyle.Width(a.width).Render("API Response Inspector v1.0.0")
+	titleText := "API Response Inspector v1.0.0"
+	if len(a.sessions) > 1 {
+		titleText = fmt.Sprintf("%s  ·  account: %s", titleText, a.sessions[a.active].Name)
+	}
+	if me := a.client.Me(); me != nil {
+		titleText = fmt.Sprintf("%s  ·  whoami: %s", titleText, me.Username)
+	} else if !a.client.Capabilities().UserContext {
+		titleText = fmt.Sprintf("%s  ·  app-only (read-only)", titleText)
+	}
+	title := TitleStyle.Width(a.width).Render(titleText)
 	b.WriteString(title)
 	b.WriteString("\n")
 
@@ -450,23 +1112,33 @@ func (a *App) View() string {
 	statusText := a.statusLine
 	if a.loading {
 		statusText += " [Loading...]"
+	} else if a.loadingMore {
+		statusText += " [Loading more...]"
 	}
 
 	// Add item counter for list views
-	if a.mode == viewTimeline && a.timeline != nil && len(a.timeline.Data) > 0 {
-		statusText = fmt.Sprintf("%s  [%d/%d]", statusText, a.currentIndex+1, len(a.timeline.Data))
-	} else if a.mode == viewSearch && a.searchResults != nil && len(a.searchResults.Data) > 0 {
-		statusText = fmt.Sprintf("%s  [%d/%d]", statusText, a.currentIndex+1, len(a.searchResults.Data))
+	if list := a.currentList(); list != nil && len(list.Data) > 0 {
+		statusText = fmt.Sprintf("%s  [%d/%d]", statusText, a.currentIndex+1, len(list.Data))
+	}
+
+	if rl, ok := a.client.RateLimit(rateLimitEndpoint(a.mode)); ok {
+		statusText = fmt.Sprintf("%s  X-RateLimit-Remaining: %d", statusText, rl.Remaining)
 	}
 
 	status := statusStyle.Width(a.width).Render(statusText)
 	b.WriteString(status)
 	b.WriteString("\n")
 
-	// Search input (if active)
-	if a.searching {
-		searchLine := SearchStyle.Render("Search: ") + a.input.View()
-		b.WriteString(searchLine)
+	// Prompt input (if active)
+	switch a.prompt {
+	case promptSearch:
+		b.WriteString(SearchStyle.Render("Search: ") + a.input.View())
+		b.WriteString("\n")
+	case promptHandle:
+		b.WriteString(SearchStyle.Render("User: @") + a.input.View())
+		b.WriteString("\n")
+	case promptRef:
+		b.WriteString(SearchStyle.Render("Open: ") + a.input.View())
 		b.WriteString("\n")
 	}
 
@@ -0,0 +1,89 @@
+package ui
+
+import "github.com/kenan/xjson/internal/transform"
+
+// maxHistory caps how many views are remembered in each direction
+const maxHistory = 50
+
+// viewState is a snapshot of what was on screen. The pagination cursor
+// lives in list.Meta, so pages loaded later are kept along with it.
+type viewState struct {
+	mode        viewMode
+	list        *transform.DisguisedResponse // timeline, search results or profile
+	thread      *transform.DisguisedPayload
+	revisions   *transform.DisguisedPayload
+	searchQuery string
+	index       int
+	cursor      int // tree cursor line
+	offset      int // viewport scroll offset
+}
+
+// history is a back/forward stack of view states
+type history struct {
+	back    []viewState
+	forward []viewState
+}
+
+// push records a state being left for a new view, dropping the forward stack
+func (h *history) push(s viewState) {
+	h.back = appendCapped(h.back, s)
+	h.forward = nil
+}
+
+// goBack returns the previous state, remembering current for goForward
+func (h *history) goBack(current viewState) (viewState, bool) {
+	if len(h.back) == 0 {
+		return viewState{}, false
+	}
+	prev := h.back[len(h.back)-1]
+	h.back = h.back[:len(h.back)-1]
+	h.forward = appendCapped(h.forward, current)
+	return prev, true
+}
+
+// goForward returns the state undone by the last goBack
+func (h *history) goForward(current viewState) (viewState, bool) {
+	if len(h.forward) == 0 {
+		return viewState{}, false
+	}
+	next := h.forward[len(h.forward)-1]
+	h.forward = h.forward[:len(h.forward)-1]
+	h.back = appendCapped(h.back, current)
+	return next, true
+}
+
+// appendCapped appends to a stack, dropping the oldest entry when full
+func appendCapped(stack []viewState, s viewState) []viewState {
+	stack = append(stack, s)
+	if len(stack) > maxHistory {
+		stack = stack[len(stack)-maxHistory:]
+	}
+	return stack
+}
+
+// holds reports whether a list is remembered in either direction
+func (h *history) holds(list *transform.DisguisedResponse) bool {
+	for _, stack := range [][]viewState{h.back, h.forward} {
+		for _, s := range stack {
+			if s.list == list {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// lastViewOf returns the most recently remembered state showing list
+func (h *history) lastViewOf(list *transform.DisguisedResponse) (viewState, bool) {
+	for i := len(h.back) - 1; i >= 0; i-- {
+		if h.back[i].list == list {
+			return h.back[i], true
+		}
+	}
+	for i := len(h.forward) - 1; i >= 0; i-- {
+		if h.forward[i].list == list {
+			return h.forward[i], true
+		}
+	}
+	return viewState{}, false
+}
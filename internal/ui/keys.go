@@ -15,13 +15,20 @@ type KeyMap struct {
 	Refresh    key.Binding
 	Search     key.Binding
 	Profile    key.Binding
+	Handle     key.Binding
 	Timeline   key.Binding
+	Thread     key.Binding
 	Expand     key.Binding
 	Collapse   key.Binding
 	Help       key.Binding
 	Quit       key.Binding
 	Enter      key.Binding
 	Escape     key.Binding
+	Forward    key.Binding
+	Account    key.Binding
+	OpenRef    key.Binding
+	Revisions  key.Binding
+	Disguise   key.Binding
 }
 
 // DefaultKeyMap returns the default keybindings
@@ -71,10 +78,18 @@ func DefaultKeyMap() KeyMap {
 			key.WithKeys("u"),
 			key.WithHelp("u", "user profile"),
 		),
+		Handle: key.NewBinding(
+			key.WithKeys("@"),
+			key.WithHelp("@", "open handle"),
+		),
 		Timeline: key.NewBinding(
 			key.WithKeys("t"),
 			key.WithHelp("t", "timeline"),
 		),
+		Thread: key.NewBinding(
+			key.WithKeys("c"),
+			key.WithHelp("c", "thread"),
+		),
 		Expand: key.NewBinding(
 			key.WithKeys("l", "right"),
 			key.WithHelp("l", "expand"),
@@ -96,9 +111,29 @@ func DefaultKeyMap() KeyMap {
 			key.WithHelp("↵", "select"),
 		),
 		Escape: key.NewBinding(
-			key.WithKeys("esc"),
+			key.WithKeys("esc", "backspace"),
 			key.WithHelp("esc", "back"),
 		),
+		Forward: key.NewBinding(
+			key.WithKeys("f"),
+			key.WithHelp("f", "forward"),
+		),
+		Account: key.NewBinding(
+			key.WithKeys("a"),
+			key.WithHelp("a", "switch account"),
+		),
+		OpenRef: key.NewBinding(
+			key.WithKeys("o"),
+			key.WithHelp("o", "open @mention/#tag"),
+		),
+		Revisions: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "edit history"),
+		),
+		Disguise: key.NewBinding(
+			key.WithKeys("d"),
+			key.WithHelp("d", "switch disguise"),
+		),
 	}
 }
 
@@ -111,8 +146,8 @@ func (k KeyMap) ShortHelp() []key.Binding {
 func (k KeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		{k.Up, k.Down, k.PageUp, k.PageDown},
-		{k.Next, k.Prev, k.Home, k.End},
-		{k.Search, k.Profile, k.Timeline, k.Refresh},
-		{k.Expand, k.Collapse, k.Help, k.Quit},
+		{k.Next, k.Prev, k.Home, k.End, k.Escape, k.Forward},
+		{k.Search, k.Profile, k.Handle, k.OpenRef, k.Timeline, k.Thread, k.Revisions, k.Refresh},
+		{k.Expand, k.Collapse, k.Account, k.Disguise, k.Help, k.Quit},
 	}
 }
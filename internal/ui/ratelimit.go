@@ -0,0 +1,115 @@
+package ui
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kenan/xjson/internal/api"
+)
+
+// rateLimitMsg reports a rate-limited fetch along with the command to retry.
+// prefetch marks a background page fetch, which leaves the view usable.
+type rateLimitMsg struct {
+	err      *api.RateLimitError
+	retry    tea.Cmd
+	prefetch bool
+}
+
+// rateLimitTickMsg drives the countdown; id ties it to one account's queue
+type rateLimitTickMsg int
+
+// pendingRetry is a rate-limited fetch waiting for its window to reset
+type pendingRetry struct {
+	err      *api.RateLimitError
+	cmd      tea.Cmd
+	prefetch bool
+}
+
+// queueRetry adds a rate-limited fetch to the pending retries, which are
+// kept in order of reset time
+func queueRetry(pending []pendingRetry, msg rateLimitMsg) []pendingRetry {
+	if msg.err.Reset.IsZero() {
+		msg.err.Reset = time.Now().Add(time.Duration(msg.err.RetryAfter) * time.Second)
+	}
+	retry := pendingRetry{err: msg.err, cmd: msg.retry, prefetch: msg.prefetch}
+	i := len(pending)
+	for i > 0 && pending[i-1].err.Reset.After(retry.err.Reset) {
+		i--
+	}
+	return slices.Insert(pending, i, retry)
+}
+
+// dueRetries starts every pending retry whose window has reset
+func (a *App) dueRetries() []tea.Cmd {
+	var cmds []tea.Cmd
+	now := time.Now()
+	for len(a.pending) > 0 && !now.Before(a.pending[0].err.Reset) {
+		retry := a.pending[0]
+		a.pending = a.pending[1:]
+		if retry.prefetch {
+			a.loadingMore = true
+		} else {
+			a.loading = true
+		}
+		cmds = append(cmds, a.forAccount(retry.cmd))
+	}
+	return cmds
+}
+
+// hasPendingPage reports whether a page fetch is waiting for its retry
+func (a *App) hasPendingPage() bool {
+	return slices.ContainsFunc(a.pending, func(r pendingRetry) bool { return r.prefetch })
+}
+
+// retryOnRateLimit wraps a fetch so that a rate-limit failure schedules a
+// single retry once the window resets. The retry itself is not wrapped.
+func retryOnRateLimit(cmd tea.Cmd) tea.Cmd {
+	return wrapRateLimit(cmd, false)
+}
+
+// retryPageOnRateLimit is retryOnRateLimit for background page fetches
+func retryPageOnRateLimit(cmd tea.Cmd) tea.Cmd {
+	return wrapRateLimit(cmd, true)
+}
+
+func wrapRateLimit(cmd tea.Cmd, prefetch bool) tea.Cmd {
+	return func() tea.Msg {
+		msg := cmd()
+		if err, ok := msg.(errMsg); ok {
+			var rateLimit *api.RateLimitError
+			if errors.As(err, &rateLimit) {
+				return rateLimitMsg{err: rateLimit, retry: cmd, prefetch: prefetch}
+			}
+		}
+		return msg
+	}
+}
+
+// countdownTick schedules the next countdown update
+func (a *App) countdownTick() tea.Cmd {
+	id := a.tickID
+	return tea.Tick(time.Second, func(time.Time) tea.Msg {
+		return rateLimitTickMsg(id)
+	})
+}
+
+// countdownLine renders the status line while waiting for a reset
+func (a *App) countdownLine() string {
+	next := a.pending[0].err
+	remaining := time.Until(next.Reset).Round(time.Second)
+	if remaining < 0 {
+		remaining = 0
+	}
+	endpoint := next.Endpoint
+	if endpoint == "" {
+		endpoint = "request"
+	}
+	line := fmt.Sprintf("429 Too Many Requests - %s - retrying in %s", endpoint, remaining)
+	if more := len(a.pending) - 1; more > 0 {
+		line += fmt.Sprintf(" (+%d more)", more)
+	}
+	return line
+}
@@ -0,0 +1,306 @@
+package ui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// nodeKind distinguishes JSON containers from scalar values
+type nodeKind int
+
+const (
+	nodeScalar nodeKind = iota
+	nodeObject
+	nodeArray
+)
+
+// jsonNode is a single value in the tree view
+type jsonNode struct {
+	key      string // object key, empty for array items and the root
+	path     string // key path used to remember fold state, e.g. "payload.metrics"
+	kind     nodeKind
+	value    string // encoded JSON for scalars
+	parent   *jsonNode
+	children []*jsonNode
+}
+
+// treeLine is one visible line of the tree view
+type treeLine struct {
+	node    *jsonNode
+	depth   int
+	text    string // JSON text without indentation
+	hint    string // fold marker shown after a collapsed container
+	closing bool   // closing brace/bracket of an expanded container
+}
+
+// treeView renders a JSON value as a foldable tree with a cursor line.
+// Fold state is keyed by path so it carries over between items.
+type treeView struct {
+	root   *jsonNode
+	lines  []treeLine
+	folded map[string]bool
+	cursor int
+}
+
+// newTreeView creates an empty tree view
+func newTreeView() treeView {
+	return treeView{folded: make(map[string]bool)}
+}
+
+// SetValue replaces the displayed value, keeping fold state
+func (t *treeView) SetValue(v interface{}) error {
+	if v == nil {
+		t.root = nil
+		t.relayout()
+		return nil
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	root, err := decodeNode(dec, nil, "", "")
+	if err != nil {
+		return err
+	}
+
+	t.root = root
+	t.relayout()
+	return nil
+}
+
+// decodeNode reads the next value from the decoder, preserving key order
+func decodeNode(dec *json.Decoder, parent *jsonNode, key, path string) (*jsonNode, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	node := &jsonNode{key: key, path: path, parent: parent}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		enc, err := json.Marshal(tok)
+		if err != nil {
+			return nil, err
+		}
+		node.kind = nodeScalar
+		node.value = string(enc)
+		return node, nil
+	}
+
+	switch delim {
+	case '{':
+		node.kind = nodeObject
+		for dec.More() {
+			keyTok, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			k, _ := keyTok.(string)
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+			child, err := decodeNode(dec, node, k, childPath)
+			if err != nil {
+				return nil, err
+			}
+			node.children = append(node.children, child)
+		}
+	case '[':
+		node.kind = nodeArray
+		for i := 0; dec.More(); i++ {
+			child, err := decodeNode(dec, node, "", fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			node.children = append(node.children, child)
+		}
+	}
+
+	// Consume the closing delimiter
+	if _, err := dec.Token(); err != nil {
+		return nil, err
+	}
+
+	return node, nil
+}
+
+// relayout rebuilds the visible lines from the tree and fold state
+func (t *treeView) relayout() {
+	t.lines = t.lines[:0]
+	if t.root != nil {
+		t.appendLines(t.root, 0, true)
+	}
+	t.clampCursor()
+}
+
+// appendLines adds the lines for a node and its visible descendants
+func (t *treeView) appendLines(n *jsonNode, depth int, last bool) {
+	prefix := ""
+	if n.key != "" {
+		k, _ := json.Marshal(n.key)
+		prefix = string(k) + ": "
+	}
+	comma := ""
+	if !last {
+		comma = ","
+	}
+
+	if n.kind == nodeScalar {
+		t.lines = append(t.lines, treeLine{node: n, depth: depth, text: prefix + n.value + comma})
+		return
+	}
+
+	open, close := "{", "}"
+	if n.kind == nodeArray {
+		open, close = "[", "]"
+	}
+
+	if len(n.children) == 0 {
+		t.lines = append(t.lines, treeLine{node: n, depth: depth, text: prefix + open + close + comma})
+		return
+	}
+
+	if t.folded[n.path] {
+		t.lines = append(t.lines, treeLine{
+			node:  n,
+			depth: depth,
+			text:  prefix + open + "…" + close,
+			hint:  foldHint(n) + comma,
+		})
+		return
+	}
+
+	t.lines = append(t.lines, treeLine{node: n, depth: depth, text: prefix + open})
+	for i, child := range n.children {
+		t.appendLines(child, depth+1, i == len(n.children)-1)
+	}
+	t.lines = append(t.lines, treeLine{node: n, depth: depth, text: close + comma, closing: true})
+}
+
+// foldHint describes the size of a collapsed container
+func foldHint(n *jsonNode) string {
+	count := len(n.children)
+	unit := "keys"
+	if n.kind == nodeArray {
+		unit = "items"
+	}
+	if count == 1 {
+		unit = strings.TrimSuffix(unit, "s")
+	}
+	return fmt.Sprintf(" %d %s", count, unit)
+}
+
+// clampCursor keeps the cursor within the visible lines
+func (t *treeView) clampCursor() {
+	if t.cursor >= len(t.lines) {
+		t.cursor = len(t.lines) - 1
+	}
+	if t.cursor < 0 {
+		t.cursor = 0
+	}
+}
+
+// MoveCursor moves the cursor by delta lines
+func (t *treeView) MoveCursor(delta int) {
+	t.cursor += delta
+	t.clampCursor()
+}
+
+// Top moves the cursor to the first line
+func (t *treeView) Top() {
+	t.cursor = 0
+}
+
+// Bottom moves the cursor to the last line
+func (t *treeView) Bottom() {
+	t.cursor = len(t.lines) - 1
+	t.clampCursor()
+}
+
+// current returns the node under the cursor
+func (t *treeView) current() *jsonNode {
+	if len(t.lines) == 0 {
+		return nil
+	}
+	return t.lines[t.cursor].node
+}
+
+// focus moves the cursor to the opening line of a node
+func (t *treeView) focus(n *jsonNode) {
+	for i, line := range t.lines {
+		if line.node == n && !line.closing {
+			t.cursor = i
+			return
+		}
+	}
+}
+
+// Expand unfolds the container under the cursor, or steps into it if already open
+func (t *treeView) Expand() {
+	n := t.current()
+	if n == nil || n.kind == nodeScalar || len(n.children) == 0 {
+		return
+	}
+
+	if t.folded[n.path] {
+		delete(t.folded, n.path)
+		t.relayout()
+		t.focus(n)
+		return
+	}
+
+	t.focus(n.children[0])
+}
+
+// Collapse folds the container under the cursor, or jumps to its parent
+func (t *treeView) Collapse() {
+	n := t.current()
+	if n == nil {
+		return
+	}
+
+	if n.kind != nodeScalar && len(n.children) > 0 && !t.folded[n.path] {
+		t.folded[n.path] = true
+		t.relayout()
+		t.focus(n)
+		return
+	}
+
+	if n.parent != nil {
+		t.focus(n.parent)
+	}
+}
+
+// Render draws the visible lines with syntax highlighting and a cursor gutter
+func (t *treeView) Render() string {
+	gutter := lipgloss.NewStyle().Foreground(primaryColor).Render("▸ ")
+	hintStyle := lipgloss.NewStyle().Foreground(mutedColor)
+
+	var b strings.Builder
+	for i, line := range t.lines {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		if i == t.cursor {
+			b.WriteString(gutter)
+		} else {
+			b.WriteString("  ")
+		}
+		b.WriteString(strings.Repeat("  ", line.depth))
+		b.WriteString(highlightJSON(line.text))
+		if line.hint != "" {
+			b.WriteString(hintStyle.Render(line.hint))
+		}
+	}
+	return b.String()
+}
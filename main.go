@@ -2,25 +2,49 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/kenan/xjson/config"
 	"github.com/kenan/xjson/internal/api"
+	"github.com/kenan/xjson/internal/api/apitest"
+	"github.com/kenan/xjson/internal/transform"
 	"github.com/kenan/xjson/internal/ui"
 )
 
 func main() {
-	if len(os.Args) > 1 {
-		switch os.Args[1] {
+	account, args := accountFlag(os.Args[1:])
+
+	if len(args) > 0 {
+		switch args[0] {
 		case "init":
 			initConfig()
 			return
 		case "auth":
-			authenticate()
+			if len(args) > 1 {
+				switch args[1] {
+				case "migrate-store":
+					migrateStore(account, args[2:])
+					return
+				case "status":
+					authStatus(account)
+					return
+				}
+			}
+			authenticate(account)
+			return
+		case "logout":
+			logout(account)
+			return
+		case "fake-server":
+			fakeServer(args[1:])
 			return
 		case "help", "-h", "--help":
 			printHelp()
@@ -28,7 +52,30 @@ func main() {
 		}
 	}
 
-	run()
+	run(account)
+}
+
+// accountFlag removes --account NAME (or --account=NAME) from args and
+// returns the account name, which is empty when not given
+func accountFlag(args []string) (string, []string) {
+	var account string
+	rest := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; {
+		case arg == "--account" || arg == "-account":
+			if i+1 < len(args) {
+				account = args[i+1]
+				i++
+			}
+		case strings.HasPrefix(arg, "--account="):
+			account = strings.TrimPrefix(arg, "--account=")
+		case strings.HasPrefix(arg, "-account="):
+			account = strings.TrimPrefix(arg, "-account=")
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return account, rest
 }
 
 func printHelp() {
@@ -40,17 +87,42 @@ Usage:
   xjson          Start the inspector
   xjson init     Create a default config file
   xjson auth     Authenticate with the API
+  xjson auth status
+                 Show who is signed in, the granted scopes and token expiry
+  xjson logout   Revoke the stored tokens and delete them
+  xjson auth migrate-store [-from file] [-to encrypted|keyring]
+                 Move stored tokens to another token store backend
+  --account NAME Use a named account from the config (with or without auth)
+  xjson fake-server [-addr 127.0.0.1:8089] [-rate-limit N]
+                 Serve fixture data for offline development
   xjson help     Show this help message
 
 Keybindings:
-  j/k, ↑/↓       Scroll up/down
+  j/k, ↑/↓       Move cursor up/down
+  h/l, ←/→       Collapse/expand JSON node
+  g/G            Top/bottom
   n/p            Next/previous item
   /              Search
+  u              Open author's profile
+  @              Open profile by handle
+  o              Open a mention, hashtag or cashtag
   r              Refresh
   t              Back to timeline
+  esc/backspace  Back to previous view
+  f              Forward
+  c              Open conversation thread
+  e              Diff the versions of an edited post
+  a              Switch to the next account
+  d              Switch disguise (rest, graphql, kubernetes, ...)
   ?              Toggle help
   q              Quit
 
+Environment:
+  XJSON_RECORD=file     Record API traffic to a cassette (credentials stripped)
+  XJSON_REPLAY=file     Replay a cassette instead of calling the API
+  XJSON_REPLAY_MODE     'lenient' (default) or 'strict' request matching
+  XJSON_TOKEN_PASSPHRASE  Passphrase for the encrypted token store
+
 Config file: ~/.xjson.yaml`)
 }
 
@@ -74,10 +146,35 @@ func initConfig() {
 	fmt.Println("  client_id: Your OAuth 2.0 Client ID")
 	fmt.Println("  client_secret: Your OAuth 2.0 Client Secret (if using confidential client)")
 	fmt.Println("  bearer_token: Your Bearer Token (for app-only auth)")
+	fmt.Println("  api_key/api_secret: Or your API Key and Secret, to get an app-only token")
 	fmt.Println("\nGet credentials at: https://developer.twitter.com/en/portal/dashboard")
 }
 
-func authenticate() {
+func authenticate(account string) {
+	cfg, acct := loadAccount(account)
+
+	if acct.ClientID == "" || acct.ClientID == "YOUR_CLIENT_ID" {
+		fmt.Println("Please configure your client_id in ~/.xjson.yaml")
+		os.Exit(1)
+	}
+
+	auth, err := newAuthenticator(cfg, acct, account)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if doAuth(auth) {
+		if account != "" {
+			fmt.Printf("You can now run 'xjson --account %s' to start the app.\n", account)
+		} else {
+			fmt.Println("You can now run 'xjson' to start the app.")
+		}
+	}
+}
+
+// loadAccount loads the config and resolves a named account, exiting on
+// failure
+func loadAccount(account string) (*config.Config, config.Account) {
 	cfg, err := config.Load()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
@@ -85,18 +182,247 @@ func authenticate() {
 		os.Exit(1)
 	}
 
-	if cfg.ClientID == "" || cfg.ClientID == "YOUR_CLIENT_ID" {
-		fmt.Println("Please configure your client_id in ~/.xjson.yaml")
+	acct, err := cfg.Account(account)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(1)
 	}
+	return cfg, acct
+}
 
-	auth := api.NewAuthenticator(cfg.ClientID, cfg.ClientSecret, cfg.RedirectURL)
-	if doAuth(auth) {
-		fmt.Println("You can now run 'xjson' to start the app.")
+func authStatus(account string) {
+	cfg, acct := loadAccount(account)
+
+	name := account
+	if name == "" {
+		name = config.DefaultAccount
+	}
+	fmt.Printf("Account:        %s\n", name)
+
+	if acct.ClientID != "" && acct.ClientID != "YOUR_CLIENT_ID" {
+		auth, err := newAuthenticator(cfg, acct, account)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Token store:    %s\n", auth.TokenStore().Backend())
+
+		stored, err := auth.TokenStore().LoadStored()
+		if err == nil {
+			printTokenStatus(cfg, auth, stored)
+			return
+		}
+		if !errors.Is(err, api.ErrNoToken) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if acct.BearerToken != "" && acct.BearerToken != "YOUR_BEARER_TOKEN" {
+		fmt.Println("Mode:           app-only bearer token (read-only, no user context)")
+		return
+	}
+	if acct.APIKey != "" && acct.APISecret != "" {
+		fmt.Println("Mode:           app-only token from api_key (read-only, no user context)")
+		return
+	}
+
+	fmt.Println("Not signed in. Run 'xjson auth' to sign in.")
+}
+
+// printTokenStatus describes a stored OAuth token, looking up the user if
+// it isn't cached yet
+func printTokenStatus(cfg *config.Config, auth *api.Authenticator, stored *api.StoredToken) {
+	handle := "unknown"
+	if user := stored.User; user != nil {
+		handle = fmt.Sprintf("@%s (%s)", user.Username, user.Name)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+
+		httpClient, err := auth.HTTPClient(ctx)
+		if err == nil {
+			client := api.NewClient(httpClient)
+			client.SetUserCache(auth.TokenStore())
+			if cfg.APIBaseURL != "" {
+				client.SetBaseURL(cfg.APIBaseURL)
+			}
+			var user *api.User
+			if user, err = client.CurrentUser(ctx); err == nil {
+				handle = fmt.Sprintf("@%s (%s)", user.Username, user.Name)
+			}
+		}
+		if err != nil {
+			handle = fmt.Sprintf("unknown (%v)", err)
+		}
+	}
+
+	scope := stored.Scope
+	if scope == "" {
+		scope = "unknown (not recorded for this token)"
 	}
+	refresh := "no"
+	if stored.RefreshToken != "" {
+		refresh = "yes"
+	}
+
+	fmt.Println("Mode:           OAuth 2.0 user context")
+	fmt.Printf("Signed in as:   %s\n", handle)
+	fmt.Printf("Scopes:         %s\n", scope)
+	fmt.Printf("Expires:        %s\n", describeExpiry(stored.Expiry))
+	fmt.Printf("Refresh token:  %s\n", refresh)
 }
 
-func run() {
+// describeExpiry formats a token expiry along with how far away it is
+func describeExpiry(expiry time.Time) string {
+	if expiry.IsZero() {
+		return "never"
+	}
+
+	stamp := expiry.Local().Format("2006-01-02 15:04")
+	left := time.Until(expiry)
+	if left <= 0 {
+		return fmt.Sprintf("%s (expired %s ago)", stamp, roundDuration(-left))
+	}
+	return fmt.Sprintf("%s (in %s)", stamp, roundDuration(left))
+}
+
+// roundDuration formats d in whole minutes, e.g. "1h52m"
+func roundDuration(d time.Duration) string {
+	d = d.Round(time.Minute)
+	if d < time.Minute {
+		return "less than a minute"
+	}
+	return strings.TrimSuffix(d.String(), "0s")
+}
+
+func logout(account string) {
+	cfg, acct := loadAccount(account)
+
+	if acct.ClientID == "" || acct.ClientID == "YOUR_CLIENT_ID" {
+		fmt.Println("No OAuth client configured, so there is no session to end.")
+		fmt.Println("App-only bearer tokens are managed in the developer portal.")
+		return
+	}
+
+	auth, err := newAuthenticator(cfg, acct, account)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	if !auth.HasStoredToken() {
+		fmt.Println("Not signed in.")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	backend := auth.TokenStore().Backend()
+	if err := auth.Logout(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		if !auth.HasStoredToken() {
+			fmt.Printf("Removed the token from %s, but X may accept it until it expires.\n", backend)
+		}
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ Revoked tokens and removed them from %s\n", backend)
+}
+
+// newAuthenticator creates an authenticator using the account's token
+// store in the configured backend
+func newAuthenticator(cfg *config.Config, acct config.Account, name string) (*api.Authenticator, error) {
+	backend, err := tokenBackend(cfg.TokenStore, cfg.TokenStore.Backend, name)
+	if err != nil {
+		return nil, err
+	}
+	auth := api.NewAuthenticator(acct.ClientID, acct.ClientSecret, acct.RedirectURL)
+	auth.SetTokenStore(api.NewTokenStoreWithBackend(backend))
+	return auth, nil
+}
+
+// tokenPassphraseEnv holds the passphrase of the encrypted token store
+const tokenPassphraseEnv = "XJSON_TOKEN_PASSPHRASE"
+
+// tokenBackend creates the token backend of the given kind for an account
+func tokenBackend(cfg config.TokenStore, kind, account string) (api.TokenBackend, error) {
+	switch kind {
+	case "", "file":
+		return api.NewFileBackend(api.TokenFilePath(account)), nil
+
+	case "encrypted":
+		path := strings.TrimSuffix(api.TokenFilePath(account), ".json") + ".enc"
+		if cfg.KeyFile != "" {
+			keyFile := cfg.KeyFile
+			if strings.HasPrefix(keyFile, "~/") {
+				home, _ := os.UserHomeDir()
+				keyFile = filepath.Join(home, keyFile[2:])
+			}
+			return api.NewEncryptedFileBackendWithKeyFile(path, keyFile)
+		}
+		passphrase := os.Getenv(tokenPassphraseEnv)
+		if passphrase == "" {
+			return nil, fmt.Errorf("the encrypted token store needs token_store.key_file or a passphrase in %s", tokenPassphraseEnv)
+		}
+		return api.NewEncryptedFileBackend(path, []byte(passphrase)), nil
+
+	case "keyring":
+		service, err := api.NewSystemSecretService()
+		if err != nil {
+			return nil, err
+		}
+		return api.NewKeyringBackend(service, account), nil
+	}
+
+	return nil, fmt.Errorf("unknown token store backend %q (want file, encrypted or keyring)", kind)
+}
+
+func migrateStore(account string, args []string) {
+	cfg, err := config.Load()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		os.Exit(1)
+	}
+
+	configured := cfg.TokenStore.Backend
+	if configured == "" {
+		configured = "file"
+	}
+
+	fs := flag.NewFlagSet("auth migrate-store", flag.ExitOnError)
+	from := fs.String("from", "file", "backend to move tokens from: file, encrypted or keyring")
+	to := fs.String("to", configured, "backend to move tokens to (default: token_store.backend)")
+	fs.Parse(args)
+
+	if *from == *to {
+		fmt.Fprintf(os.Stderr, "Tokens are already in the %s backend; pass -from or -to\n", *to)
+		os.Exit(1)
+	}
+
+	src, err := tokenBackend(cfg.TokenStore, *from, account)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	dst, err := tokenBackend(cfg.TokenStore, *to, account)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := api.MigrateTokens(src, dst); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("✓ Moved tokens from %s to %s\n", src, dst)
+	if *to != configured {
+		fmt.Printf("Set 'token_store: {backend: %s}' in xjson.yaml to use it.\n", *to)
+	}
+}
+
+func run(account string) {
 	cfg, err := config.Load()
 	if err != nil {
 		// No config - create one and start auth
@@ -112,48 +438,53 @@ func run() {
 		os.Exit(0)
 	}
 
-	var client *api.Client
-
-	// Try OAuth first
-	if cfg.ClientID != "" && cfg.ClientID != "YOUR_CLIENT_ID" {
-		auth := api.NewAuthenticator(cfg.ClientID, cfg.ClientSecret, cfg.RedirectURL)
-
-		if auth.HasStoredToken() {
-			// Try existing token
-			httpClient, err := auth.HTTPClient(context.Background())
-			if err == nil {
-				client = api.NewClient(httpClient)
-			}
-		}
-
-		// No valid token - start auth flow automatically
-		if client == nil {
-			fmt.Println("Authentication required. Starting OAuth flow...")
-			if doAuth(auth) {
-				// Auth succeeded, get client
-				httpClient, err := auth.HTTPClient(context.Background())
-				if err == nil {
-					client = api.NewClient(httpClient)
-				}
-			}
-		}
+	if account == "" {
+		account = config.DefaultAccount
 	}
-
-	// Fall back to bearer token
-	if client == nil && cfg.BearerToken != "" && cfg.BearerToken != "YOUR_BEARER_TOKEN" {
-		client = api.NewClientWithBearerToken(cfg.BearerToken)
+	acct, err := cfg.Account(account)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	disguise, err := transform.LookupDisguise(cfg.Disguise)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
+	client := newClient(cfg, acct, account, true)
 	if client == nil {
 		fmt.Println("\nNo valid authentication.")
 		fmt.Println("Please add credentials to ~/.xjson.yaml:")
 		fmt.Println("  - client_id: Your OAuth 2.0 Client ID")
 		fmt.Println("  - bearer_token: Or use a Bearer Token instead")
+		fmt.Println("  - api_key/api_secret: Or an API Key and Secret for read-only access")
 		fmt.Println("\nGet credentials at: https://developer.twitter.com/en/portal/dashboard")
 		os.Exit(1)
 	}
 
-	app := ui.NewApp(client)
+	// Other accounts are only offered if they can start without a login
+	accounts := []ui.Account{{Name: account, Client: client}}
+	for _, name := range cfg.AccountNames() {
+		if name == account {
+			continue
+		}
+		other, _ := cfg.Account(name)
+		if c := newClient(cfg, other, name, false); c != nil {
+			accounts = append(accounts, ui.Account{Name: name, Client: c})
+		}
+	}
+
+	for _, acct := range accounts {
+		if cfg.APIBaseURL != "" {
+			acct.Client.SetBaseURL(cfg.APIBaseURL)
+		}
+		acct.Client.SetRetryPolicy(retryPolicy(cfg.Retry))
+	}
+
+	app := ui.NewApp(accounts...)
+	app.SetAppOnlyQuery(cfg.AppOnly.Query())
+	app.SetDisguise(disguise)
 
 	p := tea.NewProgram(app, tea.WithAltScreen())
 	if _, err := p.Run(); err != nil {
@@ -162,77 +493,136 @@ func run() {
 	}
 }
 
-func doAuth(auth *api.Authenticator) bool {
-	// Start local server for callback
-	codeChan := make(chan string, 1)
-	errChan := make(chan error, 1)
+func fakeServer(args []string) {
+	fs := flag.NewFlagSet("fake-server", flag.ExitOnError)
+	addr := fs.String("addr", "127.0.0.1:8089", "address to listen on")
+	rateLimit := fs.Int("rate-limit", 0, "requests per endpoint per window (default 180)")
+	rateWindow := fs.Duration("rate-window", 15*time.Minute, "rate limit window")
+	pageSize := fs.Int("page-size", 0, "items per page when max_results is not set (default 10)")
+	fs.Parse(args)
+
+	server := apitest.NewServer(apitest.Options{
+		PageSize:   *pageSize,
+		RateLimit:  *rateLimit,
+		RateWindow: *rateWindow,
+	})
 
-	server := &http.Server{Addr: ":8080"}
+	fmt.Printf("Fake X API listening on http://%s/2\n", *addr)
+	fmt.Println("\nPoint xjson at it with these lines in xjson.yaml:")
+	fmt.Printf("  api_base_url: http://%s/2\n", *addr)
+	fmt.Println("  bearer_token: fake")
+	fmt.Println("  app_only: {handles: [gophernews, termtips]}")
 
-	http.HandleFunc("/callback", func(w http.ResponseWriter, r *http.Request) {
-		code := r.URL.Query().Get("code")
-		if code == "" {
-			errChan <- fmt.Errorf("no code in callback")
-			fmt.Fprintln(w, "Error: No authorization code received")
-			return
-		}
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
 
-		codeChan <- code
-		fmt.Fprintln(w, `
-			<html><body style="font-family: monospace; padding: 40px; background: #1a1a2e; color: #0f0;">
-			<h2>Authorization successful!</h2>
-			<p>You can close this window and return to the terminal.</p>
-			</body></html>
-		`)
-	})
+// newClient creates an API client for an account from a replay cassette,
+// its stored OAuth token, its bearer token or its API key, in that order. When
+// interactive is set and there is no usable token, the OAuth flow is run.
+// It returns nil if the account has no usable credentials.
+func newClient(cfg *config.Config, acct config.Account, name string, interactive bool) *api.Client {
+	// Replayed sessions need no credentials, and may include user context
+	if os.Getenv(api.EnvReplay) != "" {
+		return api.NewClient(&http.Client{Timeout: 30 * time.Second})
+	}
 
-	go func() {
-		if err := server.ListenAndServe(); err != http.ErrServerClosed {
-			errChan <- err
+	// Try OAuth first
+	if acct.ClientID != "" && acct.ClientID != "YOUR_CLIENT_ID" {
+		auth, err := newAuthenticator(cfg, acct, name)
+		if err == nil {
+			if client := oauthClient(auth, interactive); client != nil {
+				return client
+			}
+		} else if interactive {
+			fmt.Fprintf(os.Stderr, "Token store: %v\n", err)
 		}
-	}()
+	}
 
-	// Get auth URL
-	authURL, verifier, err := auth.StartAuthFlow()
-	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error starting auth: %v\n", err)
-		return false
+	// Fall back to bearer token
+	if acct.BearerToken != "" && acct.BearerToken != "YOUR_BEARER_TOKEN" {
+		return api.NewClientWithBearerToken(acct.BearerToken)
 	}
 
-	fmt.Println("┌─────────────────────────────────────────────────────────┐")
-	fmt.Println("│  Open this URL in your browser to authenticate:        │")
-	fmt.Println("└─────────────────────────────────────────────────────────┘")
-	fmt.Println()
-	fmt.Println(authURL)
-	fmt.Println()
-	fmt.Println("Waiting for authorization...")
+	// Or an app-only token derived from the API key
+	if acct.APIKey != "" && acct.APISecret != "" {
+		return api.NewClientWithAppCredentials(acct.APIKey, acct.APISecret)
+	}
 
-	// Wait for callback
-	select {
-	case code := <-codeChan:
-		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-		defer cancel()
+	return nil
+}
 
-		_, err := auth.CompleteAuthFlow(ctx, code, verifier)
-		server.Shutdown(context.Background())
+// oauthClient creates a client from the stored token, running the OAuth
+// flow first if there is none and interactive is set
+func oauthClient(auth *api.Authenticator, interactive bool) *api.Client {
+	if auth.HasStoredToken() {
+		// Try existing token
+		httpClient, err := auth.HTTPClient(context.Background())
+		if err == nil {
+			client := api.NewClient(httpClient)
+			client.SetUserCache(auth.TokenStore())
+			return client
+		}
+	}
 
-		if err != nil {
-			fmt.Fprintf(os.Stderr, "\nAuth error: %v\n", err)
-			return false
+	// No valid token - start auth flow automatically
+	if interactive {
+		fmt.Println("Authentication required. Starting OAuth flow...")
+		if doAuth(auth) {
+			// Auth succeeded, get client
+			httpClient, err := auth.HTTPClient(context.Background())
+			if err == nil {
+				client := api.NewClient(httpClient)
+				client.SetUserCache(auth.TokenStore())
+				return client
+			}
 		}
+	}
 
-		fmt.Println("\n✓ Authentication successful! Starting app...")
-		time.Sleep(1 * time.Second)
-		return true
+	return nil
+}
 
-	case err := <-errChan:
-		server.Shutdown(context.Background())
-		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		return false
+// retryPolicy applies the configured retry settings over the defaults
+func retryPolicy(cfg config.Retry) api.RetryPolicy {
+	policy := api.DefaultRetryPolicy()
+	if cfg.MaxAttempts > 0 {
+		policy.MaxAttempts = cfg.MaxAttempts
+	}
+	if cfg.BaseDelay > 0 {
+		policy.BaseDelay = cfg.BaseDelay
+	}
+	if cfg.MaxDelay > 0 {
+		policy.MaxDelay = cfg.MaxDelay
+	}
+	return policy
+}
+
+func doAuth(auth *api.Authenticator) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	_, err := auth.Authorize(ctx, func(authURL string) {
+		fmt.Println("┌─────────────────────────────────────────────────────────┐")
+		fmt.Println("│  Open this URL in your browser to authenticate:        │")
+		fmt.Println("└─────────────────────────────────────────────────────────┘")
+		fmt.Println()
+		fmt.Println(authURL)
+		fmt.Println()
+		fmt.Println("Waiting for authorization...")
+	})
 
-	case <-time.After(5 * time.Minute):
-		server.Shutdown(context.Background())
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
 		fmt.Println("\nTimeout waiting for authorization")
 		return false
+	case err != nil:
+		fmt.Fprintf(os.Stderr, "\nAuth error: %v\n", err)
+		return false
 	}
+
+	fmt.Println("\n✓ Authentication successful! Starting app...")
+	time.Sleep(1 * time.Second)
+	return true
 }